@@ -0,0 +1,293 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// BlocksVersion is the block document schema version this server understands
+const BlocksVersion = 1
+
+// Block types
+const (
+	BlockParagraph = "paragraph"
+	BlockHeading   = "heading"
+	BlockImage     = "image"
+	BlockQuote     = "quote"
+	BlockCode      = "code"
+	BlockEmbed     = "embed"
+	BlockList      = "list"
+)
+
+// embedURLs maps the embed services we allow to the player URLs they may use
+var embedURLs = map[string]*regexp.Regexp{
+	"youtube": regexp.MustCompile(`^https://www\.youtube(-nocookie)?\.com/embed/[\w-]+(\?[\w=&-]*)?$`),
+	"vimeo":   regexp.MustCompile(`^https://player\.vimeo\.com/video/\d+(\?[\w=&-]*)?$`),
+}
+
+// embedSource matches any allowed player URL, for the sanitizer
+var embedSource = regexp.MustCompile(`^https://(www\.youtube(-nocookie)?\.com/embed/|player\.vimeo\.com/video/)`)
+
+// inlinePolicy is what block text fields may contain: inline formatting and links only
+var inlinePolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("b", "strong", "i", "em", "u", "s", "mark", "code", "br", "sub", "sup")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireNoFollowOnLinks(true)
+	return p
+}()
+
+// BlockDocument is an Editor.js style document: a list of typed blocks
+type BlockDocument struct {
+	Version int     `json:"version"`
+	Blocks  []Block `json:"blocks"`
+}
+
+// Block is a single block. Data is decoded according to Type.
+type Block struct {
+	ID   string          `json:"id,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type textData struct {
+	Text string `json:"text"`
+}
+
+type headingData struct {
+	Text  string `json:"text"`
+	Level int    `json:"level"`
+}
+
+type imageData struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+	Alt     string `json:"alt"`
+}
+
+type quoteData struct {
+	Text    string `json:"text"`
+	Caption string `json:"caption"`
+}
+
+type codeData struct {
+	Code     string `json:"code"`
+	Language string `json:"language"`
+}
+
+type embedData struct {
+	Service string `json:"service"`
+	Source  string `json:"source"`
+	Embed   string `json:"embed"`
+	Caption string `json:"caption"`
+}
+
+type listData struct {
+	Style string   `json:"style"`
+	Items []string `json:"items"`
+}
+
+// ParseBlocks decodes and validates a block document. Errors name the offending block.
+func ParseBlocks(source string) (BlockDocument, error) {
+	var doc BlockDocument
+
+	decoder := json.NewDecoder(strings.NewReader(source))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return doc, fmt.Errorf("invalid block document: %v", err)
+	}
+	if doc.Version != BlocksVersion {
+		return doc, fmt.Errorf("unsupported block document version %d, expected %d", doc.Version, BlocksVersion)
+	}
+
+	for i, block := range doc.Blocks {
+		if err := validateBlock(block); err != nil {
+			return doc, fmt.Errorf("block %d (%s): %v", i+1, block.Type, err)
+		}
+	}
+	return doc, nil
+}
+
+func validateBlock(block Block) error {
+	switch block.Type {
+	case BlockParagraph:
+		var d textData
+		return decodeBlockData(block, &d)
+	case BlockHeading:
+		var d headingData
+		if err := decodeBlockData(block, &d); err != nil {
+			return err
+		}
+		if d.Level < 1 || d.Level > 6 {
+			return errors.New("level must be between 1 and 6")
+		}
+	case BlockImage:
+		var d imageData
+		if err := decodeBlockData(block, &d); err != nil {
+			return err
+		}
+		if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New("url must be an absolute http(s) URL")
+		}
+	case BlockQuote:
+		var d quoteData
+		return decodeBlockData(block, &d)
+	case BlockCode:
+		var d codeData
+		return decodeBlockData(block, &d)
+	case BlockEmbed:
+		var d embedData
+		if err := decodeBlockData(block, &d); err != nil {
+			return err
+		}
+		pattern, ok := embedURLs[d.Service]
+		if !ok {
+			return fmt.Errorf("unsupported embed service %q", d.Service)
+		}
+		if !pattern.MatchString(d.Embed) {
+			return fmt.Errorf("embed is not a %s player URL", d.Service)
+		}
+	case BlockList:
+		var d listData
+		if err := decodeBlockData(block, &d); err != nil {
+			return err
+		}
+		if d.Style != "ordered" && d.Style != "unordered" {
+			return errors.New("style must be ordered or unordered")
+		}
+	default:
+		return fmt.Errorf("unknown block type %q", block.Type)
+	}
+	return nil
+}
+
+func decodeBlockData(block Block, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(block.Data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid data: %v", err)
+	}
+	return nil
+}
+
+// BlocksToHTML renders a validated document. The result still goes through Sanitize.
+func BlocksToHTML(doc BlockDocument) string {
+	var b strings.Builder
+	for _, block := range doc.Blocks {
+		switch block.Type {
+		case BlockParagraph:
+			var d textData
+			json.Unmarshal(block.Data, &d)
+			fmt.Fprintf(&b, "<p>%s</p>\n", inline(d.Text))
+		case BlockHeading:
+			var d headingData
+			json.Unmarshal(block.Data, &d)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", d.Level, inline(d.Text), d.Level)
+		case BlockImage:
+			var d imageData
+			json.Unmarshal(block.Data, &d)
+			fmt.Fprintf(&b, "<figure><img src=\"%s\" alt=\"%s\">", html.EscapeString(d.URL), html.EscapeString(d.Alt))
+			writeCaption(&b, d.Caption)
+			b.WriteString("</figure>\n")
+		case BlockQuote:
+			var d quoteData
+			json.Unmarshal(block.Data, &d)
+			fmt.Fprintf(&b, "<figure><blockquote>%s</blockquote>", inline(d.Text))
+			writeCaption(&b, d.Caption)
+			b.WriteString("</figure>\n")
+		case BlockCode:
+			var d codeData
+			json.Unmarshal(block.Data, &d)
+			if d.Language != "" {
+				fmt.Fprintf(&b, "<pre><code class=\"language-%s\">%s</code></pre>\n", html.EscapeString(d.Language), html.EscapeString(d.Code))
+			} else {
+				fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(d.Code))
+			}
+		case BlockEmbed:
+			var d embedData
+			json.Unmarshal(block.Data, &d)
+			fmt.Fprintf(&b, "<figure><iframe src=\"%s\" allowfullscreen></iframe>", html.EscapeString(d.Embed))
+			writeCaption(&b, d.Caption)
+			b.WriteString("</figure>\n")
+		case BlockList:
+			var d listData
+			json.Unmarshal(block.Data, &d)
+			tag := "ul"
+			if d.Style == "ordered" {
+				tag = "ol"
+			}
+			fmt.Fprintf(&b, "<%s>\n", tag)
+			for _, item := range d.Items {
+				fmt.Fprintf(&b, "<li>%s</li>\n", inline(item))
+			}
+			fmt.Fprintf(&b, "</%s>\n", tag)
+		}
+	}
+	return b.String()
+}
+
+// BlocksToText flattens a validated document to plain text, one block per paragraph
+func BlocksToText(doc BlockDocument) string {
+	var parts []string
+	for _, block := range doc.Blocks {
+		switch block.Type {
+		case BlockParagraph:
+			var d textData
+			json.Unmarshal(block.Data, &d)
+			parts = append(parts, stripTags(d.Text))
+		case BlockHeading:
+			var d headingData
+			json.Unmarshal(block.Data, &d)
+			parts = append(parts, stripTags(d.Text))
+		case BlockImage:
+			var d imageData
+			json.Unmarshal(block.Data, &d)
+			parts = append(parts, stripTags(d.Caption))
+		case BlockQuote:
+			var d quoteData
+			json.Unmarshal(block.Data, &d)
+			parts = append(parts, stripTags(d.Text), stripTags(d.Caption))
+		case BlockCode:
+			var d codeData
+			json.Unmarshal(block.Data, &d)
+			parts = append(parts, d.Code)
+		case BlockEmbed:
+			var d embedData
+			json.Unmarshal(block.Data, &d)
+			parts = append(parts, stripTags(d.Caption))
+		case BlockList:
+			var d listData
+			json.Unmarshal(block.Data, &d)
+			for _, item := range d.Items {
+				parts = append(parts, stripTags(item))
+			}
+		}
+	}
+
+	var text []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			text = append(text, part)
+		}
+	}
+	return strings.Join(text, "\n\n")
+}
+
+func inline(text string) string {
+	return inlinePolicy.Sanitize(text)
+}
+
+func writeCaption(b *strings.Builder, caption string) {
+	if caption != "" {
+		fmt.Fprintf(b, "<figcaption>%s</figcaption>", inline(caption))
+	}
+}
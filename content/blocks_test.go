@@ -0,0 +1,109 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+// blockDocument wraps blocks, given as JSON, in a version 1 document
+func blockDocument(blocks ...string) string {
+	return `{"version":1,"blocks":[` + strings.Join(blocks, ",") + `]}`
+}
+
+func TestBlocksStripScripts(t *testing.T) {
+	tests := map[string]string{
+		"paragraph script":   `{"type":"paragraph","data":{"text":"Hi<script>alert(1)</script>"}}`,
+		"paragraph onerror":  `{"type":"paragraph","data":{"text":"<img src=x onerror=alert(1)>"}}`,
+		"paragraph link":     `{"type":"paragraph","data":{"text":"<a href=\"javascript:alert(1)\">click</a>"}}`,
+		"heading script":     `{"type":"heading","data":{"text":"<script>alert(1)</script>","level":2}}`,
+		"list item link":     `{"type":"list","data":{"style":"unordered","items":["<a href=\"javascript:alert(1)\">x</a>"]}}`,
+		"caption onerror":    `{"type":"image","data":{"url":"https://example.com/a.png","caption":"<b onmouseover=alert(1)>x</b>","alt":"a\" onerror=\"alert(1)"}}`,
+		"quote script":       `{"type":"quote","data":{"text":"<script>alert(1)</script>","caption":""}}`,
+		"code stays escaped": `{"type":"code","data":{"code":"<script>alert(1)</script>","language":"html"}}`,
+	}
+
+	for name, block := range tests {
+		rendered, err := Render(FormatBlocks, blockDocument(block))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		lower := strings.ToLower(rendered)
+		for _, unsafe := range []string{"<script", "onerror=", "onmouseover", "javascript:"} {
+			if strings.Contains(lower, unsafe) {
+				t.Errorf("%s: %q survived in %s", name, unsafe, rendered)
+			}
+		}
+	}
+}
+
+func TestIframeSourcesAreAllowlisted(t *testing.T) {
+	tests := []struct {
+		src  string
+		kept bool
+	}{
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", true},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", true},
+		{"https://player.vimeo.com/video/76979871", true},
+		{"https://evil.example.com/embed/x", false},
+		{"http://www.youtube.com/embed/dQw4w9WgXcQ", false},
+		{"javascript:alert(1)", false},
+	}
+
+	for _, tt := range tests {
+		rendered, err := Render(FormatHTML, `<iframe src="`+tt.src+`"></iframe>`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := strings.Contains(rendered, `src="`+tt.src+`"`); kept != tt.kept {
+			t.Errorf("%s: kept %v, want %v: %s", tt.src, kept, tt.kept, rendered)
+		}
+	}
+
+	rendered, err := Render(FormatBlocks, blockDocument(`{"type":"embed","data":{"service":"youtube","source":"https://youtu.be/dQw4w9WgXcQ","embed":"https://www.youtube.com/embed/dQw4w9WgXcQ","caption":""}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rendered, `<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" allowfullscreen="">`) {
+		t.Errorf("embed block lost its player: %s", rendered)
+	}
+}
+
+func TestParseBlocksRejectsInvalidDocuments(t *testing.T) {
+	tests := map[string]string{
+		"wrong version":      `{"version":2,"blocks":[]}`,
+		"missing version":    `{"blocks":[]}`,
+		"unknown block type": blockDocument(`{"type":"table","data":{}}`),
+		"unknown field":      blockDocument(`{"type":"paragraph","data":{"text":"x","color":"red"}}`),
+		"heading level":      blockDocument(`{"type":"heading","data":{"text":"x","level":7}}`),
+		"relative image":     blockDocument(`{"type":"image","data":{"url":"/a.png"}}`),
+		"javascript image":   blockDocument(`{"type":"image","data":{"url":"javascript:alert(1)"}}`),
+		"unknown embed":      blockDocument(`{"type":"embed","data":{"service":"evil","embed":"https://evil.example.com/x"}}`),
+		"embed outside host": blockDocument(`{"type":"embed","data":{"service":"youtube","embed":"https://evil.example.com/embed/x"}}`),
+		"list style":         blockDocument(`{"type":"list","data":{"style":"sideways","items":[]}}`),
+		"not a document":     `[]`,
+		"unknown top field":  `{"version":1,"blocks":[],"extra":true}`,
+	}
+
+	for name, source := range tests {
+		if _, err := ParseBlocks(source); err == nil {
+			t.Errorf("%s: accepted %s", name, source)
+		}
+		if _, err := Render(FormatBlocks, source); err == nil {
+			t.Errorf("%s: rendered %s", name, source)
+		}
+	}
+}
+
+func TestBlocksToText(t *testing.T) {
+	doc, err := ParseBlocks(blockDocument(
+		`{"type":"heading","data":{"text":"Title","level":1}}`,
+		`{"type":"paragraph","data":{"text":"Some <b>bold</b> text"}}`,
+		`{"type":"list","data":{"style":"ordered","items":["one","two"]}}`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := BlocksToText(doc), "Title\n\nSome bold text\n\none\n\ntwo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
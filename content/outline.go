@@ -0,0 +1,82 @@
+package content
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// wordsPerMinute is the reading speed used for the reading time estimate
+const wordsPerMinute = 200
+
+var (
+	headingTag = regexp.MustCompile(`(?s)<h([1-6])((?:\s[^>]*)?)>(.*?)</h[1-6]>`)
+	idAttr     = regexp.MustCompile(`\s+id="[^"]*"`)
+)
+
+// Heading is a table of contents entry. ID is the anchor set on the heading in the rendered HTML.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// Document is a rendered post with everything derived from its source
+type Document struct {
+	HTML        string
+	Text        string
+	WordCount   int
+	ReadingTime int // in minutes
+	Headings    []Heading
+}
+
+// Process renders source and derives its plain text, reading stats and table of contents
+func Process(format, source string) (Document, error) {
+	var doc Document
+
+	rendered, err := Render(format, source)
+	if err != nil {
+		return doc, err
+	}
+	text, err := PlainText(format, source)
+	if err != nil {
+		return doc, err
+	}
+
+	doc.HTML, doc.Headings = anchorHeadings(rendered)
+	doc.Text = text
+	doc.WordCount = len(strings.Fields(text))
+	doc.ReadingTime = int(math.Ceil(float64(doc.WordCount) / wordsPerMinute))
+	return doc, nil
+}
+
+// anchorHeadings gives every heading an id derived from its text and lists them in order.
+// IDs only change when the heading text does; repeated headings get -2, -3... suffixes.
+func anchorHeadings(rendered string) (string, []Heading) {
+	headings := []Heading{}
+	used := map[string]bool{}
+
+	anchored := headingTag.ReplaceAllStringFunc(rendered, func(tag string) string {
+		match := headingTag.FindStringSubmatch(tag)
+		level, attrs, inner := match[1], match[2], match[3]
+
+		text := strings.Join(strings.Fields(stripTags(inner)), " ")
+		base := slug.Make(text)
+		if base == "" {
+			base = "section"
+		}
+		id := base
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[id] = true
+
+		headings = append(headings, Heading{Level: int(level[0] - '0'), Text: text, ID: id})
+		return fmt.Sprintf(`<h%s id="%s"%s>%s</h%s>`, level, id, idAttr.ReplaceAllString(attrs, ""), inner, level)
+	})
+
+	return anchored, headings
+}
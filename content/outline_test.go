@@ -0,0 +1,99 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessAnchorsHeadings(t *testing.T) {
+	tests := []struct {
+		name, format, source string
+		headings             []Heading
+		html                 []string
+	}{
+		{
+			"repeated headings",
+			FormatMarkdown,
+			"# Intro\n\n## Setup\n\ntext\n\n## Setup\n\n## Setup",
+			[]Heading{{1, "Intro", "intro"}, {2, "Setup", "setup"}, {2, "Setup", "setup-2"}, {2, "Setup", "setup-3"}},
+			[]string{`<h1 id="intro">Intro</h1>`, `<h2 id="setup">Setup</h2>`, `<h2 id="setup-2">Setup</h2>`, `<h2 id="setup-3">Setup</h2>`},
+		},
+		{
+			"inline markup and punctuation",
+			FormatMarkdown,
+			"## Why *Go*, really?",
+			[]Heading{{2, "Why Go, really?", "why-go-really"}},
+			[]string{`<h2 id="why-go-really">Why <em>Go</em>, really?</h2>`},
+		},
+		{
+			"ids sent by the author are replaced",
+			FormatHTML,
+			`<h3 id="custom">Notes</h3><h3>Notes</h3>`,
+			[]Heading{{3, "Notes", "notes"}, {3, "Notes", "notes-2"}},
+			[]string{`<h3 id="notes">Notes</h3>`, `<h3 id="notes-2">Notes</h3>`},
+		},
+		{
+			"headings without text",
+			FormatHTML,
+			`<h2>!!!</h2><h2>?</h2>`,
+			[]Heading{{2, "!!!", "section"}, {2, "?", "section-2"}},
+			[]string{`<h2 id="section">!!!</h2>`, `<h2 id="section-2">?</h2>`},
+		},
+		{
+			"block headings",
+			FormatBlocks,
+			blockDocument(`{"type":"heading","data":{"text":"Part","level":2}}`, `{"type":"heading","data":{"text":"Part","level":3}}`),
+			[]Heading{{2, "Part", "part"}, {3, "Part", "part-2"}},
+			[]string{`<h2 id="part">Part</h2>`, `<h3 id="part-2">Part</h3>`},
+		},
+	}
+
+	for _, tt := range tests {
+		doc, err := Process(tt.format, tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(doc.Headings, tt.headings) {
+			t.Errorf("%s: headings = %+v, want %+v", tt.name, doc.Headings, tt.headings)
+		}
+		for _, want := range tt.html {
+			if !strings.Contains(doc.HTML, want) {
+				t.Errorf("%s: %q missing from %s", tt.name, want, doc.HTML)
+			}
+		}
+	}
+}
+
+func TestProcessCountsWords(t *testing.T) {
+	tests := []struct {
+		name, format, source string
+		words, minutes       int
+	}{
+		{"empty", FormatPlain, "", 0, 0},
+		{"markdown markup isn't counted", FormatMarkdown, "# Title\n\nSome **bold** [link](https://example.com) text", 5, 1},
+		{"a minute is 200 words", FormatPlain, strings.Repeat("word ", 200), 200, 1},
+		{"partial minutes round up", FormatPlain, strings.Repeat("word ", 201), 201, 2},
+	}
+
+	for _, tt := range tests {
+		doc, err := Process(tt.format, tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if doc.WordCount != tt.words || doc.ReadingTime != tt.minutes {
+			t.Errorf("%s: %d words, %d minutes; want %d, %d", tt.name, doc.WordCount, doc.ReadingTime, tt.words, tt.minutes)
+		}
+	}
+}
+
+func TestProcessRejectsInvalidSource(t *testing.T) {
+	for _, tt := range []struct{ format, source string }{
+		{"rtf", "text"},
+		{FormatBlocks, `{"version":2,"blocks":[]}`},
+	} {
+		if _, err := Process(tt.format, tt.source); err == nil {
+			t.Errorf("%s: accepted %s", tt.format, tt.source)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Content formats a post's source can be written in
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatPlain    = "plain"
+	FormatBlocks   = "blocks" // a JSON block document, see BlockDocument
+)
+
+// Formats lists every supported content format
+var Formats = []string{FormatMarkdown, FormatHTML, FormatPlain, FormatBlocks}
+
+var (
+	// Raw HTML inside Markdown is passed through and then sanitized like any other HTML
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+
+	sanitizer = newSanitizer()
+
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+)
+
+// ValidFormat reports whether format is a supported content format
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Render converts source in the given format to sanitized HTML
+func Render(format, source string) (string, error) {
+	switch format {
+	case FormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(source), &buf); err != nil {
+			return "", err
+		}
+		return Sanitize(buf.String()), nil
+	case FormatHTML:
+		return Sanitize(source), nil
+	case FormatPlain:
+		return renderPlain(source), nil
+	case FormatBlocks:
+		doc, err := ParseBlocks(source)
+		if err != nil {
+			return "", err
+		}
+		return Sanitize(BlocksToHTML(doc)), nil
+	default:
+		return "", fmt.Errorf("unsupported content format %q", format)
+	}
+}
+
+// Sanitize strips everything outside the allowlist from untrusted HTML
+func Sanitize(untrusted string) string {
+	return sanitizer.Sanitize(untrusted)
+}
+
+// renderPlain escapes text and turns blank-line separated blocks into paragraphs
+func renderPlain(source string) string {
+	source = strings.ReplaceAll(strings.TrimSpace(source), "\r\n", "\n")
+	if source == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, paragraph := range paragraphBreak.Split(source, -1) {
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+// newSanitizer builds the allowlist: bluemonday's user generated content policy plus
+// what the renderers emit for code highlighting, footnotes, task lists and embeds
+func newSanitizer() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(footnotes|footnote-ref|footnote-backref)$`)).OnElements("div", "a")
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-(noteref|backlink|endnotes)$`)).OnElements("a", "div")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	// Embed blocks only ever produce player iframes from the allowed services
+	p.AllowAttrs("src").Matching(embedSource).OnElements("iframe")
+	p.AllowAttrs("allowfullscreen").OnElements("iframe")
+	return p
+}
@@ -0,0 +1,83 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderStripsScripts(t *testing.T) {
+	tests := []struct {
+		name, format, source string
+	}{
+		{"markdown script", FormatMarkdown, "Hello\n\n<script>alert(1)</script>"},
+		{"markdown onerror", FormatMarkdown, `<img src="x.png" onerror="alert(1)">`},
+		{"markdown javascript link", FormatMarkdown, "[click](javascript:alert(1))"},
+		{"html script", FormatHTML, "<p>Hello</p><script>alert(1)</script>"},
+		{"html onerror", FormatHTML, `<img src="x.png" onerror="alert(1)">`},
+		{"html javascript link", FormatHTML, `<a href="javascript:alert(1)">click</a>`},
+	}
+
+	for _, tt := range tests {
+		rendered, err := Render(tt.format, tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		lower := strings.ToLower(rendered)
+		for _, unsafe := range []string{"<script", "onerror", "javascript:"} {
+			if strings.Contains(lower, unsafe) {
+				t.Errorf("%s: %q survived in %s", tt.name, unsafe, rendered)
+			}
+		}
+	}
+}
+
+func TestRenderKeepsMarkdownExtensions(t *testing.T) {
+	tests := []struct {
+		name, source string
+		want         []string
+	}{
+		{
+			"footnote",
+			"Claim[^1]\n\n[^1]: Source",
+			[]string{`<sup id="fnref:1"><a href="#fn:1" class="footnote-ref" role="doc-noteref"`, `<li id="fn:1">`, `<div class="footnotes" role="doc-endnotes">`, `class="footnote-backref" role="doc-backlink"`},
+		},
+		{
+			"task list",
+			"- [x] done\n- [ ] todo",
+			[]string{`<input checked="" disabled="" type="checkbox"> done`, `<input disabled="" type="checkbox"> todo`},
+		},
+		{
+			"code block",
+			"```go\nfmt.Println()\n```",
+			[]string{`<code class="language-go">`},
+		},
+	}
+
+	for _, tt := range tests {
+		rendered, err := Render(FormatMarkdown, tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(rendered, want) {
+				t.Errorf("%s: %q missing from %s", tt.name, want, rendered)
+			}
+		}
+	}
+}
+
+func TestRenderPlain(t *testing.T) {
+	rendered, err := Render(FormatPlain, "a <b>\nline\n\nnext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p>a &lt;b&gt;<br>\nline</p>\n<p>next</p>\n"; rendered != want {
+		t.Errorf("got %q, want %q", rendered, want)
+	}
+}
+
+func TestRenderRejectsUnknownFormats(t *testing.T) {
+	if _, err := Render("rtf", "text"); err == nil {
+		t.Error("want an error for an unknown format")
+	}
+}
@@ -0,0 +1,62 @@
+package content
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var (
+	stripPolicy = bluemonday.StrictPolicy()
+	// blockBoundary matches tags that end a line of text, so words on either side don't run together
+	blockBoundary = regexp.MustCompile(`(?i)<br\s*/?>|</(p|h[1-6]|li|blockquote|pre|div|figure|figcaption|tr|td|th)>`)
+	blankLines    = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// PlainText converts source in the given format to plain text, for summaries and search
+func PlainText(format, source string) (string, error) {
+	switch format {
+	case FormatPlain:
+		return strings.TrimSpace(source), nil
+	case FormatBlocks:
+		doc, err := ParseBlocks(source)
+		if err != nil {
+			return "", err
+		}
+		return BlocksToText(doc), nil
+	default:
+		rendered, err := Render(format, source)
+		if err != nil {
+			return "", err
+		}
+		return htmlToText(rendered), nil
+	}
+}
+
+// Summarize returns the start of text, cut at a word boundary to at most maxRunes runes
+func Summarize(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:maxRunes])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, ".,;:!? ") + "…"
+}
+
+func htmlToText(rendered string) string {
+	text := blockBoundary.ReplaceAllString(rendered, "$0\n")
+	text = html.UnescapeString(stripPolicy.Sanitize(text))
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
+
+func stripTags(fragment string) string {
+	return html.UnescapeString(stripPolicy.Sanitize(fragment))
+}
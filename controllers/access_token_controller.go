@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/utils"
+)
+
+const maxAccessTokenDays = 365
+
+func CreateAccessToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	var input models.PersonalAccessTokenCreate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name is required"})
+	}
+	if len(input.Scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "At least one scope is required"})
+	}
+	known := map[string]bool{}
+	for _, scope := range models.AllScopes {
+		known[scope] = true
+	}
+	for _, scope := range input.Scopes {
+		if !known[scope] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown scope: " + scope})
+		}
+	}
+	if input.ExpiresInDays < 0 || input.ExpiresInDays > maxAccessTokenDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "expires_in_days must be between 0 and 365"})
+	}
+
+	rawToken := models.PersonalAccessTokenPrefix + utils.GenerateToken()
+	token := models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      input.Name,
+		TokenHash: helpers.HashToken(rawToken),
+		Prefix:    rawToken[:len(models.PersonalAccessTokenPrefix)+6],
+		Scopes:    strings.Join(input.Scopes, " "),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&token).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create token"})
+	}
+
+	// The plaintext token is only ever shown here
+	response := toAccessTokenResponse(token)
+	response.Token = rawToken
+
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+func GetAccessTokens(c *fiber.Ctx) error {
+	var tokens []models.PersonalAccessToken
+	database.DB.Where("user_id = ? AND revoked_at IS NULL", c.Locals("userID")).
+		Order("created_at DESC").
+		Find(&tokens)
+
+	response := []models.PersonalAccessTokenResponse{}
+	for _, token := range tokens {
+		response = append(response, toAccessTokenResponse(token))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func RevokeAccessToken(c *fiber.Ctx) error {
+	result := database.DB.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Params("id"), c.Locals("userID")).
+		Update("revoked_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Token not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Token revoked successfully"})
+}
+
+func toAccessTokenResponse(token models.PersonalAccessToken) models.PersonalAccessTokenResponse {
+	return models.PersonalAccessTokenResponse{
+		ID:         token.ID.String(),
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     strings.Fields(token.Scopes),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
@@ -1,56 +1,210 @@
 package controllers
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
 	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+	"github.com/nurullahgd/main-blog-backend/tokens"
+	"gorm.io/gorm"
 )
 
-func GetAdminUsers(c *fiber.Ctx) error {
-	var adminUsers []models.AdminUser
-	database.DB.Find(&adminUsers)
-
-	// Convert to response format
-	var response []models.AdminUserResponse
-	for _, admin := range adminUsers {
-		response = append(response, models.AdminUserResponse{
-			ID:        admin.ID.String(),
-			Username:  admin.Username,
-			Email:     admin.Email,
-			Role:      admin.Role,
-			CreatedAt: admin.CreatedAt,
-			UpdatedAt: admin.UpdatedAt,
+const (
+	// adminTokenTTL is how long an admin_token cookie stays valid
+	adminTokenTTL = 8 * time.Hour
+
+	// adminTokenPurpose tells admin tokens apart from 2FA challenges signed with the same key
+	adminTokenPurpose = "admin_access"
+)
+
+func AdminLogin(c *fiber.Ctx) error {
+	var input models.AdminLogin
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var admin models.AdminUser
+	found := database.DB.Where("email = ? OR username = ?", input.Input, input.Input).First(&admin).Error == nil
+
+	accountKey := helpers.AccountThrottleKey(models.OwnerTypeAdmin, input.Input)
+	if found {
+		accountKey = helpers.AccountThrottleKey(models.OwnerTypeAdmin, admin.ID.String())
+	}
+	ipKey := helpers.IPThrottleKey(c.IP())
+	if wait, blocked := helpers.LoginBlocked(accountKey, ipKey); blocked {
+		return tooManyLoginAttempts(c, wait)
+	}
+
+	if !helpers.VerifyPasswordConstantTime(input.Password, admin.Password, found) {
+		helpers.RecordLoginFailure(accountKey, ipKey)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+	}
+
+	if admin.TOTPEnabled {
+		challenge, err := generateTwoFactorChallenge(admin.ID.String(), challengePurposeAdmin)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response)
+	if err := setAdminToken(c, admin); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	helpers.ResetLoginThrottle(accountKey)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Login successful",
+		"admin":   toAdminUserResponse(admin),
+	})
 }
 
-func CreateAdminUser(c *fiber.Ctx) error {
+func AdminLogout(c *fiber.Ctx) error {
+	c.Cookie(&fiber.Cookie{
+		Name:     "admin_token",
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Hour), // Expire immediately
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/",
+	})
+
+	return c.JSON(fiber.Map{"message": "Logout successful"})
+}
+
+// BootstrapAdmin creates the first super_admin. It only works while the
+// admin_users table is empty, so it can be left routed after setup.
+func BootstrapAdmin(c *fiber.Ctx) error {
 	var input models.AdminUserCreate
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	input.Role = models.RoleSuperAdmin
+
+	if msg := validateAdminUserCreate(input); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	hashedPassword, err := helpers.HashPassword(input.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
+	}
 
 	adminUser := models.AdminUser{
 		Username: input.Username,
 		Email:    input.Email,
-		Password: input.Password, // In a real application, you should hash the password
+		Password: hashedPassword,
 		Role:     input.Role,
 	}
 
-	database.DB.Create(&adminUser)
+	errAlreadyBootstrapped := fiber.NewError(fiber.StatusConflict, "Admin users already exist")
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the table so two concurrent bootstrap calls can't both see it empty
+		if err := tx.Exec("LOCK TABLE admin_users IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.AdminUser{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errAlreadyBootstrapped
+		}
 
-	response := models.AdminUserResponse{
-		ID:        adminUser.ID.String(),
-		Username:  adminUser.Username,
-		Email:     adminUser.Email,
-		Role:      adminUser.Role,
-		CreatedAt: adminUser.CreatedAt,
-		UpdatedAt: adminUser.UpdatedAt,
+		return tx.Create(&adminUser).Error
+	})
+	if err == errAlreadyBootstrapped {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
 	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create admin user"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(toAdminUserResponse(adminUser))
+}
 
-	return c.Status(fiber.StatusCreated).JSON(response)
+// adminUserListOptions are the sort keys accepted by the admin user list
+var adminUserListOptions = helpers.ListOptions{
+	Table: "admin_users",
+	Sorts: map[string]string{
+		"created_at": "admin_users.created_at",
+		"username":   "admin_users.username",
+		"role":       "admin_users.role",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetAdminUsers lists admin users a page at a time, optionally filtered by ?role= and ?from=/?to=
+func GetAdminUsers(c *fiber.Ctx) error {
+	params, err := helpers.ParseListParams(c, adminUserListOptions)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	query, err := filterCreatedAt(c, database.DB.Model(&models.AdminUser{}), "admin_users")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("admin_users.role = ?", role)
+	}
+
+	page, err := helpers.Paginate(query, params, func(admin models.AdminUser) (time.Time, string) {
+		return admin.CreatedAt, admin.ID.String()
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load admin users"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(helpers.MapListPage(page, toAdminUserResponse))
+}
+
+func CreateAdminUser(c *fiber.Ctx) error {
+	var input models.AdminUserCreate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if msg := validateAdminUserCreate(input); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	// Only a super_admin can create another super_admin
+	if input.Role == models.RoleSuperAdmin && c.Locals("adminRole") != models.RoleSuperAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden - Only a super_admin can create super_admin users"})
+	}
+
+	// Check if email or username already exists
+	var existingAdmin models.AdminUser
+	if err := database.DB.Where("email = ? OR username = ?", input.Email, input.Username).First(&existingAdmin).Error; err == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Username or email already exists"})
+	}
+
+	hashedPassword, err := helpers.HashPassword(input.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
+	}
+
+	adminUser := models.AdminUser{
+		Username: input.Username,
+		Email:    input.Email,
+		Password: hashedPassword,
+		Role:     input.Role,
+	}
+
+	if err := database.DB.Create(&adminUser).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create admin user"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(toAdminUserResponse(adminUser))
 }
 
 func DeleteBlogFromAdmin(c *fiber.Ctx) error {
@@ -60,6 +214,9 @@ func DeleteBlogFromAdmin(c *fiber.Ctx) error {
 	if err := database.DB.First(&blog, "id = ?", blogID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
 	}
+	if !policy.CanDeleteBlog(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to delete this blog"})
+	}
 	database.DB.Delete(&blog)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Blog deleted successfully"})
@@ -76,3 +233,71 @@ func DeleteUserFromAdmin(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User deleted successfully"})
 }
+
+// UnlockUserFromAdmin clears the failed login counters of a locked out user
+func UnlockUserFromAdmin(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if err := helpers.ResetLoginThrottle(helpers.AccountThrottleKey(models.OwnerTypeUser, user.ID.String())); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to unlock user"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User unlocked successfully"})
+}
+
+// setAdminToken signs an admin JWT carrying the admin's role and stores it in the admin_token cookie
+func setAdminToken(c *fiber.Ctx, admin models.AdminUser) error {
+	tokenString, err := tokens.Sign(jwt.MapClaims{
+		"user_id":  admin.ID,
+		"username": admin.Username,
+		"role":     admin.Role,
+		"purpose":  adminTokenPurpose,
+		"iss":      tokens.Issuer,
+		"aud":      tokens.AudienceAdmin,
+		"exp":      time.Now().Add(adminTokenTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "admin_token",
+		Value:    tokenString,
+		Expires:  time.Now().Add(adminTokenTTL),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/",
+	})
+	setCSRFCookie(c)
+
+	return nil
+}
+
+func validateAdminUserCreate(input models.AdminUserCreate) string {
+	if input.Username == "" || input.Email == "" {
+		return "Username and email are required"
+	}
+	if len(input.Password) < 6 {
+		return "Password must be at least 6 characters"
+	}
+	if input.Role != models.RoleAdmin && !helpers.RoleExists(input.Role) {
+		return "Unknown role"
+	}
+	return ""
+}
+
+func toAdminUserResponse(admin models.AdminUser) models.AdminUserResponse {
+	return models.AdminUserResponse{
+		ID:               admin.ID.String(),
+		Username:         admin.Username,
+		Email:            admin.Email,
+		Role:             admin.Role,
+		TwoFactorEnabled: admin.TOTPEnabled,
+		CreatedAt:        admin.CreatedAt,
+		UpdatedAt:        admin.UpdatedAt,
+	}
+}
@@ -1,40 +1,42 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/gosimple/slug"
+	"github.com/nurullahgd/main-blog-backend/content"
 	"github.com/nurullahgd/main-blog-backend/database"
 	"github.com/nurullahgd/main-blog-backend/helpers"
 	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
 	"github.com/nurullahgd/main-blog-backend/utils"
 	"gorm.io/gorm"
 )
 
+// summaryLength is how many characters of the post become its summary when none is given
+const summaryLength = 200
+
+// blogListOptions are the sort keys accepted by every blog list
+var blogListOptions = helpers.ListOptions{
+	Table: "blogs",
+	Sorts: map[string]string{
+		"created_at":   "blogs.created_at",
+		"published_at": "COALESCE(blogs.published_at, blogs.created_at)",
+		"updated_at":   "blogs.updated_at",
+		"title":        "blogs.title",
+	},
+	DefaultSort: "-created_at",
+}
+
 func GetBlogs(c *fiber.Ctx) error {
-	var blogs []models.Blog
-	database.DB.Where("visibility = ?", true).Find(&blogs)
-
-	// Convert to response format
-	var response []models.BlogResponse
-	for _, blog := range blogs {
-		response = append(response, models.BlogResponse{
-			ID:         blog.ID.String(),
-			Title:      blog.Title,
-			Content:    blog.Content,
-			MainImage:  blog.MainImage,
-			UserID:     blog.UserID,
-			Category:   blog.Category,
-			Visibility: blog.Visibility,
-			Summary:    blog.Summary,
-			CreatedAt:  blog.CreatedAt,
-			UpdatedAt:  blog.UpdatedAt,
-		})
-	}
-
-	return c.Status(fiber.StatusOK).JSON(response)
+	query := database.DB.Model(&models.Blog{}).Where("blogs.status = ?", models.BlogStatusPublished)
+	return listBlogs(c, query)
 }
 
 func GetBlog(c *fiber.Ctx) error {
@@ -44,40 +46,69 @@ func GetBlog(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
 	}
 
-	response := models.BlogResponse{
-		ID:         blog.ID.String(),
-		Title:      blog.Title,
-		Content:    blog.Content,
-		MainImage:  blog.MainImage,
-		Slug:       blog.Slug,
-		Category:   blog.Category,
-		Summary:    blog.Summary,
-		Visibility: blog.Visibility,
-		UserID:     blog.UserID,
-		CreatedAt:  blog.CreatedAt,
-		UpdatedAt:  blog.UpdatedAt,
-	}
-
-	return c.Status(fiber.StatusOK).JSON(response)
+	// Drafts look exactly like missing posts to anyone who can't edit them
+	if !policy.CanViewBlog(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
+	}
+	view, err := parseBlogView(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	loadBlogDetail(&blog)
+	if view.includeAuthor {
+		blogs := []models.Blog{blog}
+		loadBlogAuthors(blogs)
+		blog = blogs[0]
+	}
+
+	c.Set(fiber.HeaderETag, versionETag(blog.Version))
+	if view.fields != nil {
+		return c.Status(fiber.StatusOK).JSON(view.sparse(toBlogResponse(blog)))
+	}
+	return c.Status(fiber.StatusOK).JSON(toBlogResponse(blog))
 }
 
 func CreateBlog(c *fiber.Ctx) error {
-	userToken := c.Cookies("user_token")
-	userID, err := helpers.GetUserIDFromToken(userToken)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
+	userID := c.Locals("userID").(string)
 
 	// Text verileri al
 	title := c.FormValue("title")
-	content := c.FormValue("content")
+	body := c.FormValue("content")
 	visibilityStr := c.FormValue("visibility")
 	slugInput := c.FormValue("slug")
 	category := c.FormValue("category")
 	summary := c.FormValue("summary")
+	contentFormat := c.FormValue("content_format", content.FormatMarkdown)
+	// The block editor sends its document instead of content
+	if blocks := c.FormValue("blocks"); blocks != "" {
+		body = blocks
+		contentFormat = content.FormatBlocks
+	}
 	// Visibility değerini boolean'a çevir
 	visibility := visibilityStr == "true" || visibilityStr == "1"
 
+	// Status takes precedence; older clients only send visibility
+	status := c.FormValue("status")
+	if status == "" {
+		status = statusFromVisibility(visibility)
+	}
+	scheduledAt, err := parseScheduledAt(c.FormValue("scheduled_at"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	tags, _, err := tagsInput(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	categoryID, fiberErr := resolveCategoryInput(category)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+	language, err := blogLanguage(c.FormValue("language"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// Slug oluştur
 	var generatedSlug string
 	if slugInput != "" {
@@ -87,17 +118,7 @@ func CreateBlog(c *fiber.Ctx) error {
 	}
 
 	// Slug benzersiz mi kontrol et
-	uniqueSlug := generatedSlug
-	counter := 1
-	for {
-		var existing models.Blog
-		err := database.DB.Where("slug = ?", uniqueSlug).First(&existing).Error
-		if err == gorm.ErrRecordNotFound {
-			break
-		}
-		uniqueSlug = fmt.Sprintf("%s-%d", generatedSlug, counter)
-		counter++
-	}
+	uniqueSlug := uniqueBlogSlug(generatedSlug, uuid.Nil)
 
 	// Dosyayı al
 	file, err := c.FormFile("image")
@@ -113,41 +134,47 @@ func CreateBlog(c *fiber.Ctx) error {
 
 	// DB'ye kaydet
 	blog := models.Blog{
-		Title:      title,
-		Content:    content,
-		MainImage:  imageURL,
-		UserID:     userID,
-		Visibility: visibility,
-		Slug:       uniqueSlug,
-		Category:   category,
-		Summary:    summary,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-	}
-
-	if err := database.DB.Create(&blog).Error; err != nil {
+		Title:         title,
+		Content:       body,
+		ContentFormat: contentFormat,
+		Language:      language,
+		MainImage:     imageURL,
+		UserID:        userID,
+		Slug:          uniqueSlug,
+		CategoryID:    categoryID,
+		Summary:       summary,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if err := renderBlogContent(&blog); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	defaultBlogSummary(&blog)
+	if err := applyBlogStatus(&blog, status, scheduledAt); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// The first revision is recorded together with the post
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&blog).Error; err != nil {
+			return err
+		}
+		if err := setBlogTags(tx, blog.ID, tags); err != nil {
+			return err
+		}
+		return recordRevision(tx, blog, policy.ActorFromContext(c), nil)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create blog"})
 	}
 
 	// Blog sayısını arttır
 	database.DB.Model(&models.User{}).Where("id = ?", userID).Update("blog_count", gorm.Expr("blog_count + 1"))
 
+	loadBlogDetail(&blog)
+
 	// Yanıt
-	response := models.BlogResponse{
-		ID:         blog.ID.String(),
-		Title:      blog.Title,
-		Content:    blog.Content,
-		Slug:       blog.Slug,
-		MainImage:  blog.MainImage,
-		UserID:     blog.UserID,
-		Category:   blog.Category,
-		Visibility: blog.Visibility,
-		Summary:    blog.Summary,
-		CreatedAt:  blog.CreatedAt,
-		UpdatedAt:  blog.UpdatedAt,
-	}
-
-	return c.Status(fiber.StatusCreated).JSON(response)
+	return c.Status(fiber.StatusCreated).JSON(toBlogResponse(blog))
 }
 
 func UploadBlogImage(c *fiber.Ctx) error {
@@ -159,6 +186,13 @@ func UploadBlogImage(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
 	}
 
+	if !policy.CanEditBlog(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to edit this blog"})
+	}
+	if handled, err := checkIfMatch(c, blog.Version); handled {
+		return err
+	}
+
 	// Get file from form
 	file, err := c.FormFile("image")
 	if err != nil {
@@ -171,30 +205,31 @@ func UploadBlogImage(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Delete old image if exists
-	if blog.MainImage != "" {
-		publicID := utils.GetPublicIDFromURL(blog.MainImage)
-		utils.DeleteFromCloudinary(publicID)
-	}
-
 	// Update blog
+	oldImage := blog.MainImage
 	blog.MainImage = imageURL
-	database.DB.Save(&blog)
+	if err := saveVersioned(database.DB, &blog, &blog.Version); err != nil {
+		// The new upload is unused now
+		utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(imageURL))
+		if errors.Is(err, errVersionConflict) {
+			return versionConflict(c, &models.Blog{}, blog.ID)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update blog"})
+	}
 
-	response := models.BlogResponse{
-		ID:        blog.ID.String(),
-		Title:     blog.Title,
-		Content:   blog.Content,
-		MainImage: blog.MainImage,
-		UserID:    blog.UserID,
-		Summary:   blog.Summary,
-		CreatedAt: blog.CreatedAt,
-		UpdatedAt: blog.UpdatedAt,
+	// Delete old image only once the new one is saved
+	if oldImage != "" {
+		publicID := utils.GetPublicIDFromURL(oldImage)
+		utils.DeleteFromCloudinary(publicID)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response)
+	c.Set(fiber.HeaderETag, versionETag(blog.Version))
+	loadBlogDetail(&blog)
+	return c.Status(fiber.StatusOK).JSON(toBlogResponse(blog))
 }
 
+// EditBlog updates a post from a urlencoded or multipart form. Fields that aren't sent keep
+// their value; PatchBlog also accepts JSON.
 func EditBlog(c *fiber.Ctx) error {
 	blogID := c.Params("id")
 
@@ -203,38 +238,272 @@ func EditBlog(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
 	}
 
-	blog.Title = c.FormValue("title")
-	blog.Content = c.FormValue("content")
-	blog.Summary = c.FormValue("summary")
-	blog.Category = c.FormValue("category")
-	visibilityStr := c.FormValue("visibility")
-	visibility := visibilityStr == "true" || visibilityStr == "1"
-	blog.Visibility = visibility
-	blog.UpdatedAt = time.Now()
+	actor := policy.ActorFromContext(c)
+	if !policy.CanEditBlog(actor, blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to edit this blog"})
+	}
+	// Reject edits that aren't based on the current copy of the post
+	if handled, err := checkIfMatch(c, blog.Version); handled {
+		return err
+	}
 
-	database.DB.Save(&blog)
+	scheduledAt, err := parseScheduledAt(c.FormValue("scheduled_at"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	response := models.BlogResponse{
-		ID:         blog.ID.String(),
-		Title:      blog.Title,
-		Content:    blog.Content,
-		Summary:    blog.Summary,
-		Category:   blog.Category,
-		Visibility: blog.Visibility,
-		CreatedAt:  blog.CreatedAt,
-		UpdatedAt:  blog.UpdatedAt,
+	// Only the fields sent are changed, so a partial form doesn't blank or unpublish the post
+	status := c.FormValue("status")
+	if visibilityStr, ok := formField(c, "visibility"); ok && status == "" {
+		visibility := visibilityStr == "true" || visibilityStr == "1"
+		if visibility != blog.Visibility {
+			status = statusFromVisibility(visibility)
+		}
+	}
+	if status == "" && scheduledAt != nil {
+		if status, err = rescheduledStatus(blog); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if status != "" && status != blog.Status && !policy.CanPublishBlog(actor, blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to change this blog's visibility"})
+	}
+	tags, tagsSent, err := tagsInput(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if title, ok := formField(c, "title"); ok {
+		if title = strings.TrimSpace(title); title == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Title can't be empty"})
+		}
+		blog.Title = title
+	}
+	if text, ok := formField(c, "content"); ok {
+		if strings.TrimSpace(text) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Content can't be empty"})
+		}
+		blog.Content = text
+	}
+	if summary, ok := formField(c, "summary"); ok {
+		blog.Summary = summary
+	}
+	if category, ok := formField(c, "category"); ok {
+		categoryID, fiberErr := resolveCategoryInput(category)
+		if fiberErr != nil {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+		}
+		blog.CategoryID = categoryID
+	}
+	if contentFormat := c.FormValue("content_format"); contentFormat != "" {
+		blog.ContentFormat = contentFormat
+	}
+	if language := c.FormValue("language"); language != "" {
+		if blog.Language, err = blogLanguage(language); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if blocks := c.FormValue("blocks"); blocks != "" {
+		blog.Content = blocks
+		blog.ContentFormat = content.FormatBlocks
+	}
+	if err := renderBlogContent(&blog); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	defaultBlogSummary(&blog)
+	if status != "" {
+		if err := applyBlogStatus(&blog, status, scheduledAt); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	blog.UpdatedAt = time.Now()
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &blog, &blog.Version); err != nil {
+			return err
+		}
+		if tagsSent {
+			if err := setBlogTags(tx, blog.ID, tags); err != nil {
+				return err
+			}
+		}
+		return recordRevision(tx, blog, actor, nil)
+	})
+	if errors.Is(err, errVersionConflict) {
+		return versionConflict(c, &models.Blog{}, blog.ID)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update blog"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response)
+	c.Set(fiber.HeaderETag, versionETag(blog.Version))
+	loadBlogDetail(&blog)
+	return c.Status(fiber.StatusOK).JSON(toBlogResponse(blog))
 }
 
-func DeleteBlog(c *fiber.Ctx) error {
-	userToken := c.Cookies("user_token")
-	userID, err := helpers.GetUserIDFromToken(userToken)
+// PatchBlog updates only the fields present in the request. It accepts a JSON merge
+// patch or a multipart form, which may also carry a new main image.
+func PatchBlog(c *fiber.Ctx) error {
+	var blog models.Blog
+	if err := database.DB.First(&blog, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
+	}
+
+	actor := policy.ActorFromContext(c)
+	if !policy.CanEditBlog(actor, blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to edit this blog"})
+	}
+	if handled, err := checkIfMatch(c, blog.Version); handled {
+		return err
+	}
+
+	multipart := strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm)
+	var patch models.BlogPatch
+	var err error
+	if multipart {
+		patch, err = parseBlogPatchForm(c)
+	} else {
+		patch, err = parseBlogPatchJSON(c.Body())
+	}
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	contentChanged := false
+	if patch.Title != nil {
+		title := strings.TrimSpace(*patch.Title)
+		if title == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Title can't be empty"})
+		}
+		blog.Title = title
+		contentChanged = true
+	}
+	if patch.Content != nil {
+		if strings.TrimSpace(*patch.Content) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Content can't be empty"})
+		}
+		blog.Content = *patch.Content
+		contentChanged = true
+	}
+	if patch.ContentFormat != nil {
+		blog.ContentFormat = *patch.ContentFormat
+		contentChanged = true
+	}
+	if patch.Blocks != nil {
+		blog.Content = *patch.Blocks
+		blog.ContentFormat = content.FormatBlocks
+		contentChanged = true
+	}
+	if patch.Content != nil || patch.ContentFormat != nil || patch.Blocks != nil {
+		if err := renderBlogContent(&blog); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	// The language only affects search, so it doesn't make a revision
+	if patch.Language != nil {
+		if blog.Language, err = blogLanguage(*patch.Language); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if patch.Summary != nil {
+		blog.Summary = *patch.Summary
+		contentChanged = true
+	}
+	if patch.Category != nil {
+		categoryID, fiberErr := resolveCategoryInput(*patch.Category)
+		if fiberErr != nil {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+		}
+		blog.CategoryID = categoryID
+		contentChanged = true
+	}
+
+	var tags []models.Tag
+	if patch.Tags != nil {
+		if tags, err = validateTags(*patch.Tags); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	status := ""
+	if patch.Status != nil {
+		status = *patch.Status
+	} else if patch.Visibility != nil && *patch.Visibility != blog.Visibility {
+		status = statusFromVisibility(*patch.Visibility)
+	} else if patch.ScheduledAt != nil {
+		if status, err = rescheduledStatus(blog); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if status != "" {
+		if status != blog.Status && !policy.CanPublishBlog(actor, blog) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to change this blog's visibility"})
+		}
+		if err := applyBlogStatus(&blog, status, patch.ScheduledAt); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	// The slug only changes on request, so links to the post keep working
+	if patch.Slug != nil || patch.RegenerateSlug {
+		base := blog.Title
+		if patch.Slug != nil {
+			base = *patch.Slug
+		}
+		generatedSlug := slug.Make(base)
+		if generatedSlug == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Slug can't be empty"})
+		}
+		blog.Slug = uniqueBlogSlug(generatedSlug, blog.ID)
+	}
+
+	oldImage := ""
+	if multipart {
+		if file, err := c.FormFile("image"); err == nil {
+			imageURL, err := utils.UploadToCloudinary(file, "blog_images")
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			oldImage = blog.MainImage
+			blog.MainImage = imageURL
+		}
+	}
+
+	blog.UpdatedAt = time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &blog, &blog.Version); err != nil {
+			return err
+		}
+		if patch.Tags != nil {
+			if err := setBlogTags(tx, blog.ID, tags); err != nil {
+				return err
+			}
+		}
+		if !contentChanged {
+			return nil
+		}
+		return recordRevision(tx, blog, actor, nil)
+	})
+	if err != nil {
+		if oldImage != "" {
+			utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(blog.MainImage))
+		}
+		if errors.Is(err, errVersionConflict) {
+			return versionConflict(c, &models.Blog{}, blog.ID)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update blog"})
+	}
+
+	if oldImage != "" {
+		utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(oldImage))
 	}
 
+	c.Set(fiber.HeaderETag, versionETag(blog.Version))
+	loadBlogDetail(&blog)
+	return c.Status(fiber.StatusOK).JSON(toBlogResponse(blog))
+}
+
+func DeleteBlog(c *fiber.Ctx) error {
 	blogID := c.Params("id")
 
 	var blog models.Blog
@@ -242,7 +511,7 @@ func DeleteBlog(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Blog not found"})
 	}
 
-	if blog.UserID != userID {
+	if !policy.CanDeleteBlog(policy.ActorFromContext(c), blog) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to delete this blog"})
 	}
 
@@ -259,22 +528,451 @@ func ChangeVisibility(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Blog not found"})
 	}
 
-	blog.Visibility = !blog.Visibility
-	database.DB.Save(&blog)
+	if !policy.CanPublishBlog(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to change this blog's visibility"})
+	}
+	if handled, err := checkIfMatch(c, blog.Version); handled {
+		return err
+	}
+
+	// Toggle between published and draft
+	applyBlogStatus(&blog, statusFromVisibility(!blog.Visibility), nil)
+	if err := saveVersioned(database.DB, &blog, &blog.Version); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			return versionConflict(c, &models.Blog{}, blog.ID)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to change visibility"})
+	}
+	c.Set(fiber.HeaderETag, versionETag(blog.Version))
 
 	return c.JSON(fiber.Map{"message": "Visibility changed successfully"})
 }
 
+// UpdateBlogStatus moves a post through its lifecycle: draft, scheduled, published, archived or unlisted
+func UpdateBlogStatus(c *fiber.Ctx) error {
+	var blog models.Blog
+	if err := database.DB.First(&blog, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
+	}
+
+	if !policy.CanPublishBlog(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to change this blog's status"})
+	}
+	if handled, err := checkIfMatch(c, blog.Version); handled {
+		return err
+	}
+
+	var input models.BlogStatusUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := applyBlogStatus(&blog, input.Status, input.ScheduledAt); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := saveVersioned(database.DB, &blog, &blog.Version); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			return versionConflict(c, &models.Blog{}, blog.ID)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update blog status"})
+	}
+
+	c.Set(fiber.HeaderETag, versionETag(blog.Version))
+	loadBlogDetail(&blog)
+	return c.Status(fiber.StatusOK).JSON(toBlogResponse(blog))
+}
+
 func FetchMyBlogs(c *fiber.Ctx) error {
-	userToken := c.Cookies("user_token")
-	fmt.Println("userToken", userToken)
-	userID, err := helpers.GetUserIDFromToken(userToken)
+	userID := c.Locals("userID").(string)
+
+	// Authors see all their posts; ?status= and ?visibility= narrow them down
+	query := database.DB.Model(&models.Blog{}).Where("blogs.user_id = ?", userID)
+	return listBlogs(c, query)
+}
+
+// listBlogs responds with one page of the posts matched by query
+func listBlogs(c *fiber.Ctx, query *gorm.DB) error {
+	page, fiberErr := pageOfBlogs(c, query)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+	return c.Status(fiber.StatusOK).JSON(page)
+}
+
+// pageOfBlogs applies the request's filters, sort and paging to query. Posts are rendered
+// as cards unless ?fields= asks for other fields.
+func pageOfBlogs(c *fiber.Ctx, query *gorm.DB) (helpers.ListPage[interface{}], *fiber.Error) {
+	params, err := helpers.ParseListParams(c, blogListOptions)
+	if err != nil {
+		return helpers.ListPage[interface{}]{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	view, err := parseBlogView(c)
+	if err != nil {
+		return helpers.ListPage[interface{}]{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	query, fiberErr := filterBlogs(c, query)
+	if fiberErr != nil {
+		return helpers.ListPage[interface{}]{}, fiberErr
+	}
+
+	page, err := helpers.Paginate(query.Omit(view.omittedColumns()...), params, blogCursorKey)
+	if err != nil {
+		return helpers.ListPage[interface{}]{}, fiber.NewError(fiber.StatusInternalServerError, "Failed to load blogs")
+	}
+	view.load(page.Data)
+
+	return helpers.MapListPage(page, view.card), nil
+}
+
+// filterBlogs applies ?author= (username), ?category= (slug, including subcategories),
+// ?from=/?to= (creation date), ?status= and ?visibility=
+func filterBlogs(c *fiber.Ctx, query *gorm.DB) (*gorm.DB, *fiber.Error) {
+	if author := c.Query("author"); author != "" {
+		query = query.Where("blogs.user_id IN (SELECT id FROM users WHERE username = ?)", author)
+	}
+	if category := c.Query("category"); category != "" {
+		categoryID, fiberErr := resolveCategoryInput(category)
+		if fiberErr != nil {
+			return nil, fiberErr
+		}
+		ids, err := categoryDescendantIDs(database.DB, *categoryID)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to load categories")
+		}
+		query = query.Where("blogs.category_id IN ?", ids)
+	}
+	query, err := filterCreatedAt(c, query, "blogs")
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if status := c.Query("status"); status != "" {
+		if !isBlogStatus(status) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid status, must be one of "+strings.Join(models.AllBlogStatuses, ", "))
+		}
+		query = query.Where("blogs.status = ?", status)
+	}
+	if visibility := c.Query("visibility"); visibility != "" {
+		query = query.Where("blogs.visibility = ?", visibility == "true" || visibility == "1")
+	}
+
+	return query, nil
+}
+
+func blogCursorKey(blog models.Blog) (time.Time, string) {
+	return blog.CreatedAt, blog.ID.String()
+}
+
+func toBlogResponse(blog models.Blog) models.BlogResponse {
+	response := models.BlogResponse{
+		ID:              blog.ID.String(),
+		Title:           blog.Title,
+		Content:         blog.Content,
+		ContentFormat:   blog.ContentFormat,
+		Language:        blog.Language,
+		Slug:            blog.Slug,
+		MainImage:       blog.MainImage,
+		UserID:          blog.UserID,
+		Visibility:      blog.Visibility,
+		Summary:         blog.Summary,
+		Status:          blog.Status,
+		PublishedAt:     blog.PublishedAt,
+		ScheduledAt:     blog.ScheduledAt,
+		WordCount:       blog.WordCount,
+		ReadingTime:     blog.ReadingTime,
+		TableOfContents: blog.TableOfContents,
+		Version:         blog.Version,
+		CreatedAt:       blog.CreatedAt,
+		UpdatedAt:       blog.UpdatedAt,
+	}
+	if blog.ContentHTML != nil {
+		response.ContentHTML = *blog.ContentHTML
+	}
+	if blog.Author != nil {
+		author := toAuthorResponse(*blog.Author)
+		response.Author = &author
+	}
+	if blog.Category != nil {
+		response.Category = &models.CategorySummary{
+			ID:   blog.Category.ID.String(),
+			Name: blog.Category.Name,
+			Slug: blog.Category.Slug,
+		}
+	}
+	response.Tags = []models.TagResponse{}
+	for _, tag := range blog.Tags {
+		response.Tags = append(response.Tags, toTagResponse(tag, nil))
+	}
+	if blog.ContentFormat == content.FormatBlocks && json.Valid([]byte(blog.Content)) {
+		response.Blocks = json.RawMessage(blog.Content)
+	}
+	return response
+}
+
+// loadBlogDetails fills in the tags and category of every post
+func loadBlogDetails(blogs []models.Blog) {
+	loadBlogTags(blogs)
+	loadBlogCategories(blogs)
+}
+
+// loadBlogDetail fills in the tags and category of a single post
+func loadBlogDetail(blog *models.Blog) {
+	blogs := []models.Blog{*blog}
+	loadBlogDetails(blogs)
+	*blog = blogs[0]
+}
+
+// renderBlogContent validates the post's content format and caches the sanitized HTML,
+// the plain text, reading stats and table of contents
+func renderBlogContent(blog *models.Blog) error {
+	if blog.ContentFormat == "" {
+		blog.ContentFormat = content.FormatMarkdown
+	}
+	if !content.ValidFormat(blog.ContentFormat) {
+		return fmt.Errorf("Invalid content format, must be one of %s", strings.Join(content.Formats, ", "))
+	}
+
+	doc, err := content.Process(blog.ContentFormat, blog.Content)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		return fmt.Errorf("Invalid content: %v", err)
+	}
+	blog.ContentHTML = &doc.HTML
+	blog.ContentText = &doc.Text
+	blog.WordCount = doc.WordCount
+	blog.ReadingTime = doc.ReadingTime
+	blog.TableOfContents = doc.Headings
+	return nil
+}
+
+// defaultBlogSummary fills an empty summary from the start of the post's plain text
+func defaultBlogSummary(blog *models.Blog) {
+	if strings.TrimSpace(blog.Summary) == "" && blog.ContentText != nil {
+		blog.Summary = content.Summarize(*blog.ContentText, summaryLength)
+	}
+}
+
+// applyBlogStatus validates a status change and updates the dependent fields.
+// Visibility mirrors whether the post can be read publicly.
+func applyBlogStatus(blog *models.Blog, status string, scheduledAt *time.Time) error {
+	switch status {
+	case models.BlogStatusDraft, models.BlogStatusArchived:
+		blog.ScheduledAt = nil
+	case models.BlogStatusPublished, models.BlogStatusUnlisted:
+		blog.ScheduledAt = nil
+		if blog.PublishedAt == nil {
+			now := time.Now()
+			blog.PublishedAt = &now
+		}
+	case models.BlogStatusScheduled:
+		if scheduledAt == nil {
+			return errors.New("scheduled_at is required for scheduled posts")
+		}
+		if !scheduledAt.After(time.Now()) {
+			return errors.New("scheduled_at must be in the future")
+		}
+		blog.ScheduledAt = scheduledAt
+	default:
+		return fmt.Errorf("Invalid status, must be one of %s", strings.Join(models.AllBlogStatuses, ", "))
+	}
+
+	blog.Status = status
+	blog.Visibility = status == models.BlogStatusPublished || status == models.BlogStatusUnlisted
+	return nil
+}
+
+// rescheduledStatus is the status a post keeps when scheduled_at comes without a status:
+// a scheduled post moves to the new time. Any other post would ignore the time, so it
+// is rejected instead of being dropped.
+func rescheduledStatus(blog models.Blog) (string, error) {
+	if blog.Status != models.BlogStatusScheduled {
+		return "", errors.New(`scheduled_at needs status "scheduled" unless the post is already scheduled`)
 	}
+	return models.BlogStatusScheduled, nil
+}
+
+// isBlogStatus reports whether status is a valid blog status
+func isBlogStatus(status string) bool {
+	for _, s := range models.AllBlogStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// blogLanguage validates a post's text search language; empty means the default
+func blogLanguage(language string) (string, error) {
+	if language == "" {
+		return models.DefaultBlogLanguage, nil
+	}
+	for _, supported := range models.SearchLanguages {
+		if language == supported {
+			return language, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid language, must be one of %s", strings.Join(models.SearchLanguages, ", "))
+}
 
-	var blogs []models.Blog
-	database.DB.Where("user_id = ?", userID).Find(&blogs)
+func statusFromVisibility(visibility bool) string {
+	if visibility {
+		return models.BlogStatusPublished
+	}
+	return models.BlogStatusDraft
+}
 
-	return c.JSON(blogs)
+// parseScheduledAt parses an optional RFC 3339 form value
+func parseScheduledAt(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("scheduled_at must be an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
+
+// uniqueBlogSlug appends a counter to base until no other post uses it. Soft-deleted
+// posts count too, since the unique index still covers them.
+func uniqueBlogSlug(base string, blogID uuid.UUID) string {
+	uniqueSlug := base
+	counter := 1
+	for {
+		var existing models.Blog
+		err := database.DB.Unscoped().Where("slug = ? AND id <> ?", uniqueSlug, blogID).First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			return uniqueSlug
+		}
+		uniqueSlug = fmt.Sprintf("%s-%d", base, counter)
+		counter++
+	}
+}
+
+// parseBlogPatchJSON reads a JSON merge patch. Unknown fields are rejected so typos
+// don't silently do nothing.
+func parseBlogPatchJSON(body []byte) (models.BlogPatch, error) {
+	var patch models.BlogPatch
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return patch, errors.New("Invalid JSON body")
+	}
+	if len(raw) == 0 {
+		return patch, errors.New("No fields to update")
+	}
+
+	for key, value := range raw {
+		null := string(value) == "null"
+		var err error
+		switch key {
+		case "title":
+			patch.Title, err = decodePatchString(value)
+		case "content":
+			patch.Content, err = decodePatchString(value)
+		case "content_format":
+			patch.ContentFormat, err = decodePatchString(value)
+		case "blocks":
+			// The document is sent as a JSON object and stored as its source text
+			if null || len(value) == 0 || value[0] != '{' {
+				return patch, errors.New("blocks must be a block document object")
+			}
+			blocks := string(value)
+			patch.Blocks = &blocks
+		case "language":
+			patch.Language, err = decodePatchString(value)
+		case "summary":
+			patch.Summary, err = decodePatchString(value)
+		case "category":
+			patch.Category, err = decodePatchString(value)
+		case "status":
+			patch.Status, err = decodePatchString(value)
+		case "slug":
+			patch.Slug, err = decodePatchString(value)
+		case "tags":
+			// null removes every tag, like an empty list
+			tags := []string{}
+			if !null {
+				err = json.Unmarshal(value, &tags)
+			}
+			patch.Tags = &tags
+		case "scheduled_at":
+			if !null {
+				err = json.Unmarshal(value, &patch.ScheduledAt)
+			}
+		case "visibility":
+			if !null {
+				err = json.Unmarshal(value, &patch.Visibility)
+			}
+		case "regenerate_slug":
+			if !null {
+				err = json.Unmarshal(value, &patch.RegenerateSlug)
+			}
+		default:
+			return patch, fmt.Errorf("Unknown field %q", key)
+		}
+		if err != nil {
+			return patch, fmt.Errorf("Invalid value for %q", key)
+		}
+	}
+
+	return patch, nil
+}
+
+// decodePatchString decodes a string field, treating null as the empty string
+func decodePatchString(value json.RawMessage) (*string, error) {
+	var s *string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = new(string)
+	}
+	return s, nil
+}
+
+// parseBlogPatchForm reads a multipart patch. A field counts as supplied when it is present, even if empty.
+func parseBlogPatchForm(c *fiber.Ctx) (models.BlogPatch, error) {
+	var patch models.BlogPatch
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return patch, errors.New("Invalid form body")
+	}
+	value := func(key string) *string {
+		if values, ok := form.Value[key]; ok && len(values) > 0 {
+			return &values[0]
+		}
+		return nil
+	}
+
+	patch.Title = value("title")
+	patch.Content = value("content")
+	patch.ContentFormat = value("content_format")
+	patch.Blocks = value("blocks")
+	patch.Language = value("language")
+	patch.Summary = value("summary")
+	patch.Category = value("category")
+	patch.Status = value("status")
+	patch.Slug = value("slug")
+	if v := value("tags"); v != nil {
+		tags := parseTagList(*v)
+		patch.Tags = &tags
+	}
+	if v := value("visibility"); v != nil {
+		visibility := *v == "true" || *v == "1"
+		patch.Visibility = &visibility
+	}
+	if v := value("regenerate_slug"); v != nil {
+		patch.RegenerateSlug = *v == "true" || *v == "1"
+	}
+	if v := value("scheduled_at"); v != nil {
+		if patch.ScheduledAt, err = parseScheduledAt(*v); err != nil {
+			return patch, err
+		}
+	}
+
+	if len(form.Value) == 0 && len(form.File["image"]) == 0 {
+		return patch, errors.New("No fields to update")
+	}
+	return patch, nil
 }
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+func TestEditBlogKeepsFieldsThatAreNotSent(t *testing.T) {
+	f := newPolicyFixture(t)
+	category := models.Category{Name: "Partial", Slug: "partial-" + testSuffix()}
+	if err := database.DB.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// An editor can't change visibility, so a form without it must not try to
+	for _, name := range []string{"owner", "editor"} {
+		blog := f.sharedBlog(t, models.BlogStatusPublished)
+		database.DB.Model(&blog).UpdateColumn("category_id", category.ID)
+
+		path := "/blogs/" + blog.ID.String() + "/edit"
+		status, body := doTestRequest(t, newActorApp(f.actors[name]), testRequest(http.MethodPost, path, fiber.MIMEApplicationForm, "title=Only+the+title", blog.Version))
+		if status != fiber.StatusOK {
+			t.Fatalf("%s: status %d: %s", name, status, body)
+		}
+
+		var after models.Blog
+		database.DB.First(&after, "id = ?", blog.ID)
+		if after.Title != "Only the title" {
+			t.Errorf("%s: title = %q, want the sent one", name, after.Title)
+		}
+		if after.Content != blog.Content || after.Summary != blog.Summary {
+			t.Errorf("%s: content or summary changed: %q, %q", name, after.Content, after.Summary)
+		}
+		if after.Status != models.BlogStatusPublished || !after.Visibility {
+			t.Errorf("%s: post is %s (visible %v), want it still published", name, after.Status, after.Visibility)
+		}
+		if after.CategoryID == nil || *after.CategoryID != category.ID {
+			t.Errorf("%s: category cleared", name)
+		}
+	}
+
+	blog := f.sharedBlog(t, models.BlogStatusPublished)
+	path := "/blogs/" + blog.ID.String() + "/edit"
+	app := newActorApp(f.actors["owner"])
+	for _, form := range []string{"title=+", "content="} {
+		if status, body := doTestRequest(t, app, testRequest(http.MethodPost, path, fiber.MIMEApplicationForm, form, blog.Version)); status != fiber.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400: %s", form, status, body)
+		}
+	}
+
+	// Sent fields still apply, including an empty category
+	database.DB.Model(&blog).UpdateColumn("category_id", category.ID)
+	if status, body := doTestRequest(t, app, testRequest(http.MethodPost, path, fiber.MIMEApplicationForm, "visibility=false&category=", blog.Version)); status != fiber.StatusOK {
+		t.Fatalf("unpublish: status %d: %s", status, body)
+	}
+	var after models.Blog
+	database.DB.First(&after, "id = ?", blog.ID)
+	if after.Status != models.BlogStatusDraft || after.CategoryID != nil {
+		t.Errorf("after unpublishing: status %s, category %v", after.Status, after.CategoryID)
+	}
+}
+
+func TestBlogListsHideCategoryLookupFailures(t *testing.T) {
+	useUnreachableDB(t)
+	app := fiber.New()
+	app.Get("/blogs", GetBlogs)
+	app.Get("/blogs/search", SearchBlogs)
+	for _, path := range []string{"/blogs?category=news", "/blogs/search?q=go&category=news"} {
+		status, body := doTestRequest(t, app, testRequest(http.MethodGet, path, "", "", 0))
+		if status != fiber.StatusInternalServerError || body != `{"error":"Failed to load categories"}` {
+			t.Errorf("%s: status %d, body %s; want a 500 without the database error", path, status, body)
+		}
+	}
+}
+
+func TestBlogListsRejectUnknownCategories(t *testing.T) {
+	openTestDB(t)
+	app := fiber.New()
+	app.Get("/blogs", GetBlogs)
+	app.Get("/blogs/search", SearchBlogs)
+
+	for _, path := range []string{"/blogs?category=missing-" + testSuffix(), "/blogs/search?q=go&category=missing-" + testSuffix()} {
+		status, body := doTestRequest(t, app, testRequest(http.MethodGet, path, "", "", 0))
+		if status != fiber.StatusBadRequest || !strings.Contains(body, "Unknown category") {
+			t.Errorf("%s: status %d, body %s; want 400 Unknown category", path, status, body)
+		}
+	}
+}
+
+func TestScheduledAtWithoutStatusNeedsAScheduledPost(t *testing.T) {
+	// The mocked post is a draft, so both forms of edit must reject the time instead of dropping it
+	scheduledAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name, method, path, contentType, body string
+	}{
+		{"edit", http.MethodPost, "/blogs/{id}/edit", fiber.MIMEApplicationForm, "scheduled_at=" + url.QueryEscape(scheduledAt)},
+		{"patch", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"scheduled_at":"` + scheduledAt + `"}`},
+	}
+
+	for _, tt := range tests {
+		mockBlogDB(t)
+		path := strings.ReplaceAll(tt.path, "{id}", stubBlogID)
+		status, body := doTestRequest(t, newActorApp(stubActors["owner"]), testRequest(tt.method, path, tt.contentType, tt.body, 1))
+		if status != fiber.StatusBadRequest || !strings.Contains(body, "scheduled_at needs status") {
+			t.Errorf("%s: status %d, want 400: %s", tt.name, status, body)
+		}
+	}
+}
+
+func TestEditAndPatchRescheduleScheduledPosts(t *testing.T) {
+	f := newPolicyFixture(t)
+	app := newActorApp(f.actors["owner"])
+	at := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name, method, path, contentType, body string
+	}{
+		{"edit", http.MethodPost, "/blogs/{id}/edit", fiber.MIMEApplicationForm, "scheduled_at=" + url.QueryEscape(at.Format(time.RFC3339))},
+		{"patch", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"scheduled_at":"` + at.Format(time.RFC3339) + `"}`},
+	}
+	for _, tt := range tests {
+		blog := f.sharedBlog(t, models.BlogStatusScheduled)
+		path := strings.ReplaceAll(tt.path, "{id}", blog.ID.String())
+		if status, body := doTestRequest(t, app, testRequest(tt.method, path, tt.contentType, tt.body, blog.Version)); status != fiber.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.name, status, body)
+		}
+
+		var after models.Blog
+		database.DB.First(&after, "id = ?", blog.ID)
+		if after.Status != models.BlogStatusScheduled || after.ScheduledAt == nil || !after.ScheduledAt.Equal(at) {
+			t.Errorf("%s: post is %s at %v, want scheduled at %v", tt.name, after.Status, after.ScheduledAt, at)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+// blogFields are the keys ?fields= accepts, those of models.BlogResponse
+var blogFields = []string{
+	"id", "title", "content", "content_format", "language", "content_html", "blocks", "word_count",
+	"reading_time", "table_of_contents", "tags", "slug", "main_image", "user_id", "author",
+	"category", "visibility", "summary", "status", "published_at", "scheduled_at", "version",
+	"created_at", "updated_at",
+}
+
+// blogView is how a request wants its posts: lists default to cards, ?fields= picks
+// the keys of the full response instead and ?include=author embeds the author's profile
+type blogView struct {
+	fields        map[string]bool // nil unless ?fields= was given
+	includeAuthor bool
+}
+
+// parseBlogView reads ?fields= and ?include=. The error is safe to show to the client.
+func parseBlogView(c *fiber.Ctx) (blogView, error) {
+	var view blogView
+	for _, name := range strings.Split(c.Query("include"), ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "author":
+			view.includeAuthor = true
+		default:
+			return view, fmt.Errorf("Invalid include %q, must be author", name)
+		}
+	}
+
+	if value := c.Query("fields"); value != "" {
+		view.fields = map[string]bool{"id": true}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if !isBlogField(name) {
+				return view, fmt.Errorf("Invalid field %q, must be one of %s", name, strings.Join(blogFields, ", "))
+			}
+			view.fields[name] = true
+		}
+		// Asking for the author field is the same as including it
+		if view.fields["author"] {
+			view.includeAuthor = true
+		}
+		if view.includeAuthor {
+			view.fields["author"] = true
+		}
+	}
+
+	return view, nil
+}
+
+func isBlogField(name string) bool {
+	for _, field := range blogFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// omittedColumns lists the large columns the view doesn't need, so lists never load
+// post bodies just to throw them away
+func (v blogView) omittedColumns() []string {
+	omit := []string{"content_text"}
+	if !v.fields["content"] && !v.fields["blocks"] {
+		omit = append(omit, "content")
+	}
+	if !v.fields["content_html"] {
+		omit = append(omit, "content_html")
+	}
+	if !v.fields["table_of_contents"] {
+		omit = append(omit, "table_of_contents")
+	}
+	return omit
+}
+
+// load fills in the related rows the view shows, one query each
+func (v blogView) load(blogs []models.Blog) {
+	if v.fields == nil || v.fields["tags"] {
+		loadBlogTags(blogs)
+	}
+	if v.fields == nil || v.fields["category"] {
+		loadBlogCategories(blogs)
+	}
+	if v.includeAuthor {
+		loadBlogAuthors(blogs)
+	}
+}
+
+// card renders a post in a list: a card by default, or only the requested fields
+func (v blogView) card(blog models.Blog) interface{} {
+	response := toBlogResponse(blog)
+	if v.fields == nil {
+		return models.BlogCard{
+			ID:          response.ID,
+			Title:       response.Title,
+			Slug:        response.Slug,
+			Summary:     response.Summary,
+			MainImage:   response.MainImage,
+			UserID:      response.UserID,
+			Author:      response.Author,
+			Category:    response.Category,
+			Tags:        response.Tags,
+			WordCount:   response.WordCount,
+			ReadingTime: response.ReadingTime,
+			Status:      response.Status,
+			PublishedAt: response.PublishedAt,
+			CreatedAt:   response.CreatedAt,
+		}
+	}
+	return v.sparse(response)
+}
+
+// sparse keeps only the requested keys of a full response
+func (v blogView) sparse(response models.BlogResponse) map[string]json.RawMessage {
+	data, _ := json.Marshal(response)
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+	for key := range fields {
+		if !v.fields[key] {
+			delete(fields, key)
+		}
+	}
+	return fields
+}
+
+// loadBlogAuthors fills in the public profile of every post's author with a single query
+func loadBlogAuthors(blogs []models.Blog) {
+	var ids []string
+	for _, blog := range blogs {
+		ids = append(ids, blog.UserID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var users []models.User
+	database.DB.Select("id", "name", "surname", "username", "profile_image").Where("id IN ?", ids).Find(&users)
+	byID := map[string]*models.User{}
+	for i := range users {
+		byID[users[i].ID.String()] = &users[i]
+	}
+	for i := range blogs {
+		blogs[i].Author = byID[blogs[i].UserID]
+	}
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The post the mocked database finds, and the users related to it
+const (
+	stubBlogID     = "10000000-0000-0000-0000-000000000001"
+	stubOwnerID    = "10000000-0000-0000-0000-000000000002"
+	stubCoauthorID = "10000000-0000-0000-0000-000000000003"
+	stubEditorID   = "10000000-0000-0000-0000-000000000004"
+	stubStrangerID = "10000000-0000-0000-0000-000000000005"
+)
+
+// stubLookups answers the policy's lookups from memory, like the database in newPolicyFixture
+type stubLookups struct{}
+
+func (stubLookups) RoleHasPermissions(role string, permissions ...string) (bool, error) {
+	for _, permission := range permissions {
+		if role != testModeratorRole || permission != models.PermBlogsModerate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (stubLookups) CollaboratorRole(blogID uuid.UUID, userID string) (string, error) {
+	if blogID.String() == stubBlogID {
+		switch userID {
+		case stubCoauthorID:
+			return models.CollaboratorRoleCoAuthor, nil
+		case stubEditorID:
+			return models.CollaboratorRoleEditor, nil
+		}
+	}
+	return "", errors.New("record not found")
+}
+
+var stubActors = map[string]policy.Actor{
+	"owner":     {UserID: stubOwnerID, Lookups: stubLookups{}},
+	"coauthor":  {UserID: stubCoauthorID, Lookups: stubLookups{}},
+	"editor":    {UserID: stubEditorID, Lookups: stubLookups{}},
+	"moderator": {AdminID: "admin-1", AdminRole: testModeratorRole, Lookups: stubLookups{}},
+	"admin":     {AdminID: "admin-2", AdminRole: testSupportRole, Lookups: stubLookups{}},
+	"stranger":  {UserID: stubStrangerID, Lookups: stubLookups{}},
+	"anonymous": {Lookups: stubLookups{}},
+}
+
+// mockBlogDB points database.DB at a mock that finds the stub post once and fails
+// every other query, so a request stops right after the policy check
+func mockBlogDB(t *testing.T) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery(`SELECT \* FROM "blogs"`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "user_id", "title", "status", "visibility", "version"}).
+			AddRow(stubBlogID, stubOwnerID, "Stub post", models.BlogStatusDraft, false, 1),
+	)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = gormDB
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+	})
+}
+
+// TestBlogRoutesCheckThePolicy runs without Postgres: denied actors must get a 403,
+// allowed actors must get past the check
+func TestBlogRoutesCheckThePolicy(t *testing.T) {
+	editors := []string{"owner", "coauthor", "editor", "moderator"}
+	publishers := []string{"owner", "coauthor", "moderator"}
+	managers := []string{"owner", "moderator"}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		allowed     []string
+	}{
+		{"edit", http.MethodPost, "/blogs/{id}/edit", fiber.MIMEApplicationForm, "title=Edited&content=Edited+body", editors},
+		{"patch", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"title":"Patched"}`, editors},
+		{"patch status", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"status":"published"}`, publishers},
+		{"change visibility", http.MethodPost, "/blogs/{id}/visibility", "", "", publishers},
+		{"update status", http.MethodPut, "/blogs/{id}/status", fiber.MIMEApplicationJSON, `{"status":"archived"}`, publishers},
+		{"upload image", http.MethodPost, "/blogs/{id}/main-image", "", "", editors},
+		{"delete", http.MethodDelete, "/blogs/{id}", "", "", managers},
+		{"list revisions", http.MethodGet, "/blogs/{id}/revisions", "", "", editors},
+		{"get revision", http.MethodGet, "/blogs/{id}/revisions/1", "", "", editors},
+		{"diff revisions", http.MethodGet, "/blogs/{id}/revisions/diff?from=1&to=1", "", "", editors},
+		{"restore revision", http.MethodPost, "/blogs/{id}/revisions/1/restore", "", "", editors},
+		{"revision retention", http.MethodPut, "/blogs/{id}/revisions/retention", fiber.MIMEApplicationJSON, `{"limit":10}`, managers},
+		{"list collaborators", http.MethodGet, "/blogs/{id}/collaborators", "", "", editors},
+		{"add collaborator", http.MethodPost, "/blogs/{id}/collaborators", fiber.MIMEApplicationJSON, `{"username":"someone","role":"editor"}`, managers},
+		{"remove editor", http.MethodDelete, "/blogs/{id}/collaborators/" + stubEditorID, "", "", append([]string{"editor"}, managers...)},
+		{"remove co-author", http.MethodDelete, "/blogs/{id}/collaborators/" + stubCoauthorID, "", "", append([]string{"coauthor"}, managers...)},
+	}
+
+	for _, tt := range tests {
+		path := strings.ReplaceAll(tt.path, "{id}", stubBlogID)
+		for _, name := range actorNames {
+			mockBlogDB(t)
+			status, body := doTestRequest(t, newActorApp(stubActors[name]), testRequest(tt.method, path, tt.contentType, tt.body, 1))
+
+			if allowed := contains(tt.allowed, name); allowed == (status == fiber.StatusForbidden) {
+				t.Errorf("%s as %s: status %d, allowed %v: %s", tt.name, name, status, allowed, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+)
+
+const (
+	testModeratorRole = "test_moderator"
+	testSupportRole   = "test_support"
+)
+
+// policyFixture holds a user for every relation to a post and the actors built from them
+type policyFixture struct {
+	owner, coauthor, editor, stranger models.User
+	actors                            map[string]policy.Actor
+}
+
+// actorNames lists the fixture's actors in the order cases are run
+var actorNames = []string{"owner", "coauthor", "editor", "moderator", "admin", "stranger", "anonymous"}
+
+func newPolicyFixture(t *testing.T) *policyFixture {
+	t.Helper()
+	openTestDB(t)
+	grantTestRole(t, testModeratorRole, models.PermBlogsModerate)
+	grantTestRole(t, testSupportRole, models.PermUsersRead)
+
+	f := &policyFixture{
+		owner:    createTestUser(t, "owner"),
+		coauthor: createTestUser(t, "coauthor"),
+		editor:   createTestUser(t, "editor"),
+		stranger: createTestUser(t, "stranger"),
+	}
+	f.actors = map[string]policy.Actor{
+		"owner":     {UserID: f.owner.ID.String()},
+		"coauthor":  {UserID: f.coauthor.ID.String()},
+		"editor":    {UserID: f.editor.ID.String()},
+		"moderator": {AdminID: uuid.NewString(), AdminRole: testModeratorRole},
+		"admin":     {AdminID: uuid.NewString(), AdminRole: testSupportRole},
+		"stranger":  {UserID: f.stranger.ID.String()},
+		"anonymous": {},
+	}
+	return f
+}
+
+// sharedBlog creates a post of the owner that the co-author and the editor work on
+func (f *policyFixture) sharedBlog(t *testing.T, status string) models.Blog {
+	t.Helper()
+	blog := createTestBlog(t, f.owner, "Shared post", status)
+	addTestCollaborator(t, blog, f.coauthor, models.CollaboratorRoleCoAuthor)
+	addTestCollaborator(t, blog, f.editor, models.CollaboratorRoleEditor)
+	return blog
+}
+
+// newActorApp serves the blog and profile routes as the actor, the way the auth middlewares would
+// leave the request
+func newActorApp(actor policy.Actor) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", actor.UserID)
+		c.Locals("adminID", actor.AdminID)
+		c.Locals("adminRole", actor.AdminRole)
+		return c.Next()
+	})
+	if actor.Lookups != nil {
+		app.Use(policy.UseLookups(actor.Lookups))
+	}
+
+	app.Get("/blogs/search", SearchBlogs)
+	app.Post("/blogs/:id/edit", EditBlog)
+	app.Patch("/blogs/:id", PatchBlog)
+	app.Delete("/blogs/:id", DeleteBlog)
+	app.Post("/blogs/:id/visibility", ChangeVisibility)
+	app.Put("/blogs/:id/status", UpdateBlogStatus)
+	app.Post("/blogs/:id/main-image", UploadBlogImage)
+	app.Get("/blogs/:id/revisions", GetBlogRevisions)
+	app.Get("/blogs/:id/revisions/diff", DiffBlogRevisions)
+	app.Get("/blogs/:id/revisions/:number", GetBlogRevision)
+	app.Post("/blogs/:id/revisions/:number/restore", RestoreBlogRevision)
+	app.Put("/blogs/:id/revisions/retention", UpdateRevisionRetention)
+	app.Get("/blogs/:id/collaborators", GetBlogCollaborators)
+	app.Post("/blogs/:id/collaborators", AddBlogCollaborator)
+	app.Delete("/blogs/:id/collaborators/:userId", RemoveBlogCollaborator)
+	app.Put("/users/edit", EditUser)
+	app.Post("/users/profile-image", UploadProfileImage)
+	return app
+}
+
+// testRequest builds a request carrying If-Match for the version, unless it is 0
+func testRequest(method, path, contentType, body string, version int) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	if version > 0 {
+		req.Header.Set(fiber.HeaderIfMatch, versionETag(version))
+	}
+	return req
+}
+
+func doTestRequest(t *testing.T, app *fiber.App, req *http.Request) (int, string) {
+	t.Helper()
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestBlogRoutesEnforceThePolicy(t *testing.T) {
+	f := newPolicyFixture(t)
+
+	editors := []string{"owner", "coauthor", "editor", "moderator"}
+	publishers := []string{"owner", "coauthor", "moderator"}
+	managers := []string{"owner", "moderator"}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string // {id} is replaced by the post's ID
+		contentType string
+		body        string
+		allowed     []string
+		status      int // the answer allowed actors get
+	}{
+		{"edit", http.MethodPost, "/blogs/{id}/edit", fiber.MIMEApplicationForm, "title=Edited&content=Edited+body", editors, fiber.StatusOK},
+		{"patch", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"title":"Patched"}`, editors, fiber.StatusOK},
+		{"patch status", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"status":"published"}`, publishers, fiber.StatusOK},
+		{"change visibility", http.MethodPost, "/blogs/{id}/visibility", "", "", publishers, fiber.StatusOK},
+		{"update status", http.MethodPut, "/blogs/{id}/status", fiber.MIMEApplicationJSON, `{"status":"archived"}`, publishers, fiber.StatusOK},
+		// Allowed actors get as far as looking for the file
+		{"upload image", http.MethodPost, "/blogs/{id}/main-image", "", "", editors, fiber.StatusBadRequest},
+		{"delete", http.MethodDelete, "/blogs/{id}", "", "", managers, fiber.StatusOK},
+		{"list revisions", http.MethodGet, "/blogs/{id}/revisions", "", "", editors, fiber.StatusOK},
+		{"get revision", http.MethodGet, "/blogs/{id}/revisions/1", "", "", editors, fiber.StatusOK},
+		{"diff revisions", http.MethodGet, "/blogs/{id}/revisions/diff?from=1&to=1", "", "", editors, fiber.StatusOK},
+		{"restore revision", http.MethodPost, "/blogs/{id}/revisions/1/restore", "", "", editors, fiber.StatusOK},
+		{"revision retention", http.MethodPut, "/blogs/{id}/revisions/retention", fiber.MIMEApplicationJSON, `{"limit":10}`, managers, fiber.StatusOK},
+		{"list collaborators", http.MethodGet, "/blogs/{id}/collaborators", "", "", editors, fiber.StatusOK},
+		{"add collaborator", http.MethodPost, "/blogs/{id}/collaborators", fiber.MIMEApplicationJSON, `{"username":"` + f.stranger.Username + `","role":"editor"}`, managers, fiber.StatusOK},
+		// Collaborators may leave a post, but not remove each other
+		{"remove editor", http.MethodDelete, "/blogs/{id}/collaborators/" + f.editor.ID.String(), "", "", append([]string{"editor"}, managers...), fiber.StatusOK},
+		{"remove co-author", http.MethodDelete, "/blogs/{id}/collaborators/" + f.coauthor.ID.String(), "", "", append([]string{"coauthor"}, managers...), fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		for _, name := range actorNames {
+			// Every request gets a fresh draft, so earlier cases can't change the outcome
+			blog := f.sharedBlog(t, models.BlogStatusDraft)
+			path := strings.ReplaceAll(tt.path, "{id}", blog.ID.String())
+
+			want := fiber.StatusForbidden
+			if contains(tt.allowed, name) {
+				want = tt.status
+			}
+			status, body := doTestRequest(t, newActorApp(f.actors[name]), testRequest(tt.method, path, tt.contentType, tt.body, blog.Version))
+			if status != want {
+				t.Errorf("%s as %s: status %d, want %d: %s", tt.name, name, status, want, body)
+				continue
+			}
+
+			if want == fiber.StatusForbidden {
+				var after models.Blog
+				database.DB.Unscoped().First(&after, "id = ?", blog.ID)
+				if after.DeletedAt.Valid || after.Version != blog.Version || after.Title != blog.Title {
+					t.Errorf("%s as %s: the post changed despite the 403", tt.name, name)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchListsOnlyAllowedPosts(t *testing.T) {
+	f := newPolicyFixture(t)
+	word := "policy" + testSuffix()
+
+	published := createTestBlog(t, f.owner, "Published "+word, models.BlogStatusPublished)
+	draft := createTestBlog(t, f.owner, "Draft "+word, models.BlogStatusDraft)
+	addTestCollaborator(t, draft, f.coauthor, models.CollaboratorRoleCoAuthor)
+	addTestCollaborator(t, draft, f.editor, models.CollaboratorRoleEditor)
+	strangerDraft := createTestBlog(t, f.stranger, "Stranger "+word, models.BlogStatusDraft)
+
+	tests := map[string][]models.Blog{
+		"owner":     {published, draft},
+		"coauthor":  {published, draft},
+		"editor":    {published, draft},
+		"moderator": {published, draft, strangerDraft},
+		"admin":     {published},
+		"stranger":  {published, strangerDraft},
+		"anonymous": {published},
+	}
+
+	for name, posts := range tests {
+		status, body := doTestRequest(t, newActorApp(f.actors[name]), testRequest(http.MethodGet, "/blogs/search?limit=100&q="+url.QueryEscape(word), "", "", 0))
+		if status != fiber.StatusOK {
+			t.Errorf("search as %s: status %d: %s", name, status, body)
+			continue
+		}
+		var page struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatalf("search as %s: %v", name, err)
+		}
+
+		var got, want []string
+		for _, result := range page.Data {
+			got = append(got, result.ID)
+		}
+		for _, post := range posts {
+			want = append(want, post.ID.String())
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("search as %s found %v, want %v", name, got, want)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
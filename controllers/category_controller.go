@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/utils"
+	"gorm.io/gorm"
+)
+
+// errUnknownCategory is returned by resolveCategory when no category has the given slug
+var errUnknownCategory = errors.New("Unknown category")
+
+// GetCategories returns the whole category tree
+func GetCategories(c *fiber.Ctx) error {
+	var categories []models.Category
+	database.DB.Find(&categories)
+
+	children := map[uuid.UUID][]models.Category{}
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var build func(nodes []models.Category) []models.CategoryResponse
+	build = func(nodes []models.Category) []models.CategoryResponse {
+		sort.Slice(nodes, func(i, j int) bool {
+			if nodes[i].Position != nodes[j].Position {
+				return nodes[i].Position < nodes[j].Position
+			}
+			return nodes[i].Name < nodes[j].Name
+		})
+		response := []models.CategoryResponse{}
+		for _, node := range nodes {
+			item := toCategoryResponse(node)
+			item.Children = build(children[node.ID])
+			response = append(response, item)
+		}
+		return response
+	}
+
+	return c.Status(fiber.StatusOK).JSON(build(roots))
+}
+
+// GetCategoryBlogs lists the published posts in a category, with the same paging and filters
+// as GetBlogs. Posts in subcategories are included unless ?include_descendants=false.
+func GetCategoryBlogs(c *fiber.Ctx) error {
+	var category models.Category
+	if err := database.DB.First(&category, "slug = ?", c.Params("slug")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	categoryIDs := []uuid.UUID{category.ID}
+	if c.Query("include_descendants") != "false" {
+		ids, err := categoryDescendantIDs(database.DB, category.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load categories"})
+		}
+		categoryIDs = ids
+	}
+
+	query := database.DB.Model(&models.Blog{}).
+		Where("blogs.category_id IN ? AND blogs.status = ?", categoryIDs, models.BlogStatusPublished)
+	page, fiberErr := pageOfBlogs(c, query)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(struct {
+		Category models.CategoryResponse `json:"category"`
+		helpers.ListPage[interface{}]
+	}{toCategoryResponse(category), page})
+}
+
+// CreateCategory adds a category. It accepts JSON or a multipart form with an optional cover `image`.
+func CreateCategory(c *fiber.Ctx) error {
+	var input models.CategoryInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Name == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name is required"})
+	}
+
+	var category models.Category
+	if err := applyCategoryInput(&category, input); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	imageURL, fiberErr := uploadCategoryCover(c)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+	category.CoverImage = imageURL
+
+	if err := database.DB.Create(&category).Error; err != nil {
+		if imageURL != "" {
+			utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(imageURL))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create category"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(toCategoryResponse(category))
+}
+
+// UpdateCategory changes the supplied fields of a category, including moving it in the tree
+func UpdateCategory(c *fiber.Ctx) error {
+	var category models.Category
+	if err := database.DB.First(&category, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	var input models.CategoryInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := applyCategoryInput(&category, input); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	imageURL, fiberErr := uploadCategoryCover(c)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+	oldImage := ""
+	if imageURL != "" {
+		oldImage = category.CoverImage
+		category.CoverImage = imageURL
+	}
+
+	if err := database.DB.Save(&category).Error; err != nil {
+		if imageURL != "" {
+			utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(imageURL))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update category"})
+	}
+	if oldImage != "" {
+		utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(oldImage))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(toCategoryResponse(category))
+}
+
+// DeleteCategory removes a category without subcategories. Its posts become uncategorized.
+func DeleteCategory(c *fiber.Ctx) error {
+	var category models.Category
+	if err := database.DB.First(&category, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	var children int64
+	database.DB.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children)
+	if children > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Move or delete the subcategories first"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Blog{}).Where("category_id = ?", category.ID).UpdateColumn("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete category"})
+	}
+
+	if category.CoverImage != "" {
+		utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(category.CoverImage))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Category deleted successfully"})
+}
+
+// applyCategoryInput validates the supplied fields and copies them onto the category
+func applyCategoryInput(category *models.Category, input models.CategoryInput) *fiber.Error {
+	if input.Name != nil {
+		name := strings.Join(strings.Fields(*input.Name), " ")
+		if name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Name can't be empty")
+		}
+		category.Name = name
+	}
+
+	// New categories take their slug from the name; existing ones keep theirs unless one is given
+	if input.Slug != nil || category.Slug == "" {
+		base := category.Name
+		if input.Slug != nil {
+			base = *input.Slug
+		}
+		categorySlug := slug.Make(base)
+		if categorySlug == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Slug can't be empty")
+		}
+		var existing models.Category
+		if err := database.DB.First(&existing, "slug = ? AND id <> ?", categorySlug, category.ID).Error; err == nil {
+			return fiber.NewError(fiber.StatusConflict, "A category with this slug already exists")
+		}
+		category.Slug = categorySlug
+	}
+
+	if input.ParentID != nil {
+		if *input.ParentID == "" {
+			category.ParentID = nil
+		} else {
+			parentID, err := uuid.Parse(*input.ParentID)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Invalid parent_id")
+			}
+			var parent models.Category
+			if err := database.DB.First(&parent, "id = ?", parentID).Error; err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Parent category not found")
+			}
+			// A category can't be moved below itself or one of its descendants
+			if category.ID != uuid.Nil {
+				descendants, err := categoryDescendantIDs(database.DB, category.ID)
+				if err != nil {
+					return fiber.NewError(fiber.StatusInternalServerError, "Failed to load categories")
+				}
+				for _, id := range descendants {
+					if id == parentID {
+						return fiber.NewError(fiber.StatusBadRequest, "A category can't be moved into its own subtree")
+					}
+				}
+			}
+			category.ParentID = &parentID
+		}
+	}
+
+	if input.Description != nil {
+		category.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Position != nil {
+		category.Position = *input.Position
+	}
+	return nil
+}
+
+// uploadCategoryCover uploads the optional `image` file of a multipart request
+func uploadCategoryCover(c *fiber.Ctx) (string, *fiber.Error) {
+	file, err := c.FormFile("image")
+	if err != nil {
+		return "", nil
+	}
+	if file.Size > 5*1024*1024 {
+		return "", fiber.NewError(fiber.StatusBadRequest, "File size too large. Maximum size is 5MB")
+	}
+	imageURL, err := utils.UploadToCloudinary(file, "category_images")
+	if err != nil {
+		return "", fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return imageURL, nil
+}
+
+// categoryDescendantIDs returns the category and every category below it
+func categoryDescendantIDs(tx *gorm.DB, id uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := tx.Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = ?
+			UNION
+			SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT id FROM tree`, id,
+	).Scan(&ids).Error
+	return ids, err
+}
+
+// resolveCategory looks up the category a post is assigned to by slug (or name, which
+// normalizes to it). An empty value means no category.
+func resolveCategory(value string) (*uuid.UUID, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var category models.Category
+	if err := database.DB.First(&category, "slug = ?", slug.Make(value)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w %q", errUnknownCategory, value)
+		}
+		return nil, err
+	}
+	return &category.ID, nil
+}
+
+// resolveCategoryInput resolves a category sent by the client. An unknown category is
+// the client's mistake and answered with 400; lookup failures are hidden behind a 500.
+func resolveCategoryInput(value string) (*uuid.UUID, *fiber.Error) {
+	id, err := resolveCategory(value)
+	if errors.Is(err, errUnknownCategory) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to load categories")
+	}
+	return id, nil
+}
+
+// categorySlug returns the slug of a post's category, or "" if it has none
+func categorySlug(tx *gorm.DB, categoryID *uuid.UUID) (string, error) {
+	if categoryID == nil {
+		return "", nil
+	}
+	var categorySlug string
+	err := tx.Model(&models.Category{}).Where("id = ?", *categoryID).Select("slug").Scan(&categorySlug).Error
+	return categorySlug, err
+}
+
+// loadBlogCategories fills in the category of every post with a single query
+func loadBlogCategories(blogs []models.Blog) {
+	var ids []uuid.UUID
+	for _, blog := range blogs {
+		if blog.CategoryID != nil {
+			ids = append(ids, *blog.CategoryID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var categories []models.Category
+	database.DB.Where("id IN ?", ids).Find(&categories)
+	byID := map[uuid.UUID]*models.Category{}
+	for i := range categories {
+		byID[categories[i].ID] = &categories[i]
+	}
+	for i := range blogs {
+		if blogs[i].CategoryID != nil {
+			blogs[i].Category = byID[*blogs[i].CategoryID]
+		}
+	}
+}
+
+func toCategoryResponse(category models.Category) models.CategoryResponse {
+	response := models.CategoryResponse{
+		ID:          category.ID.String(),
+		Name:        category.Name,
+		Slug:        category.Slug,
+		Description: category.Description,
+		CoverImage:  category.CoverImage,
+		Position:    category.Position,
+	}
+	if category.ParentID != nil {
+		parentID := category.ParentID.String()
+		response.ParentID = &parentID
+	}
+	return response
+}
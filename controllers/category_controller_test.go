@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+func TestResolveCategoryInputHidesLookupFailures(t *testing.T) {
+	useUnreachableDB(t)
+
+	_, err := resolveCategoryInput("news")
+	if err == nil || err.Code != fiber.StatusInternalServerError || err.Message != "Failed to load categories" {
+		t.Errorf("got %v, want a 500 without the database error", err)
+	}
+	// No category doesn't need the database
+	if id, err := resolveCategoryInput(" "); id != nil || err != nil {
+		t.Errorf("empty category: got %v, %v", id, err)
+	}
+}
+
+func TestResolveCategoryInput(t *testing.T) {
+	openTestDB(t)
+	category := models.Category{Name: "Resolve", Slug: "resolve-" + testSuffix()}
+	if err := database.DB.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if id, err := resolveCategoryInput(category.Slug); err != nil || id == nil || *id != category.ID {
+		t.Errorf("known category: got %v, %v", id, err)
+	}
+	_, err := resolveCategoryInput("missing-" + testSuffix())
+	if err == nil || err.Code != fiber.StatusBadRequest || !strings.Contains(err.Message, "Unknown category") {
+		t.Errorf("unknown category: got %v, want 400", err)
+	}
+}
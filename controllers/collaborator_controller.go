@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+)
+
+// GetBlogCollaborators lists the users who share a post. Anyone who can edit the post can see them.
+func GetBlogCollaborators(c *fiber.Ctx) error {
+	var blog models.Blog
+	if err := database.DB.First(&blog, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
+	}
+	if !policy.CanEditBlog(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to view this blog's collaborators"})
+	}
+
+	var response []models.BlogCollaboratorResponse
+	database.DB.Table("blog_collaborators").
+		Select("blog_collaborators.user_id, users.username, blog_collaborators.role, blog_collaborators.created_at").
+		Joins("JOIN users ON users.id = blog_collaborators.user_id").
+		Where("blog_collaborators.blog_id = ?", blog.ID).
+		Order("blog_collaborators.created_at").
+		Scan(&response)
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// AddBlogCollaborator grants a user a role on a post, or changes the role they already have
+func AddBlogCollaborator(c *fiber.Ctx) error {
+	var blog models.Blog
+	if err := database.DB.First(&blog, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
+	}
+	if !policy.CanManageCollaborators(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to manage this blog's collaborators"})
+	}
+
+	var input models.BlogCollaboratorCreate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Role != models.CollaboratorRoleCoAuthor && input.Role != models.CollaboratorRoleEditor {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role must be coauthor or editor"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "username = ?", input.Username).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if user.ID.String() == blog.UserID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The owner can't be a collaborator"})
+	}
+
+	collaborator := models.BlogCollaborator{
+		BlogID: blog.ID,
+		UserID: user.ID.String(),
+		Role:   input.Role,
+	}
+	err := database.DB.
+		Where(models.BlogCollaborator{BlogID: blog.ID, UserID: collaborator.UserID}).
+		Assign(models.BlogCollaborator{Role: input.Role}).
+		FirstOrCreate(&collaborator).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add collaborator"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.BlogCollaboratorResponse{
+		UserID:    collaborator.UserID,
+		Username:  user.Username,
+		Role:      collaborator.Role,
+		CreatedAt: collaborator.CreatedAt,
+	})
+}
+
+// RemoveBlogCollaborator revokes a user's access to a post. Collaborators may also remove themselves.
+func RemoveBlogCollaborator(c *fiber.Ctx) error {
+	var blog models.Blog
+	if err := database.DB.First(&blog, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
+	}
+
+	actor := policy.ActorFromContext(c)
+	userID := c.Params("userId")
+	if actor.UserID != userID && !policy.CanManageCollaborators(actor, blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to manage this blog's collaborators"})
+	}
+
+	result := database.DB.Where("blog_id = ? AND user_id = ?", blog.ID, userID).Delete(&models.BlogCollaborator{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove collaborator"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Collaborator not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Collaborator removed successfully"})
+}
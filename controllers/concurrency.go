@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"gorm.io/gorm"
+)
+
+// errVersionConflict means the row changed between loading and saving it
+var errVersionConflict = errors.New("version conflict")
+
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchSatisfied reports whether the If-Match header allows writing over the given
+// version. Requests without the header are not constrained here; checkIfMatch answers
+// them with 428. If-Match uses strong comparison, so weak (W/) tags never match.
+func ifMatchSatisfied(c *fiber.Ctx, version int) bool {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == versionETag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch rejects a write that doesn't say which version it is based on (428) or
+// is based on an outdated one (412). handled is true when the response has been written.
+func checkIfMatch(c *fiber.Ctx, version int) (handled bool, err error) {
+	if c.Get(fiber.HeaderIfMatch) == "" {
+		return true, preconditionRequired(c, version)
+	}
+	if !ifMatchSatisfied(c, version) {
+		return true, preconditionFailed(c, version)
+	}
+	return false, nil
+}
+
+// preconditionFailed answers 412 with the version the client should rebase on
+func preconditionFailed(c *fiber.Ctx, version int) error {
+	c.Set(fiber.HeaderETag, versionETag(version))
+	return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+		"error":           "The resource was modified by someone else",
+		"current_version": version,
+	})
+}
+
+// preconditionRequired answers 428 to an edit that doesn't say which version it is based on
+func preconditionRequired(c *fiber.Ctx, version int) error {
+	c.Set(fiber.HeaderETag, versionETag(version))
+	return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+		"error":           "If-Match header is required",
+		"current_version": version,
+	})
+}
+
+// versionConflict answers 412 after a save lost the race, reloading the current version of the row
+func versionConflict(c *fiber.Ctx, model interface{}, id interface{}) error {
+	var version int
+	database.DB.Model(model).Where("id = ?", id).Select("version").Scan(&version)
+	return preconditionFailed(c, version)
+}
+
+// saveVersioned writes the model only if its version is still the one that was loaded,
+// bumping it on success. Without columns every field is written.
+func saveVersioned(tx *gorm.DB, model interface{}, version *int, columns ...string) error {
+	loaded := *version
+	*version = loaded + 1
+
+	query := tx.Model(model).Where("version = ?", loaded)
+	if len(columns) > 0 {
+		query = query.Select(append(columns, "version", "updated_at"))
+	} else {
+		query = query.Select("*")
+	}
+
+	result := query.Updates(model)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = errVersionConflict
+	}
+	if result.Error != nil {
+		*version = loaded
+		return result.Error
+	}
+	return nil
+}
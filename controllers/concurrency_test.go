@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+)
+
+func TestEditsRequireIfMatch(t *testing.T) {
+	openTestDB(t)
+	owner := createTestUser(t, "owner")
+	app := newActorApp(policy.Actor{UserID: owner.ID.String()})
+
+	tests := []struct {
+		name, method, path, contentType, body string
+		status                                int // the answer once the precondition holds
+	}{
+		{"edit blog", http.MethodPost, "/blogs/{id}/edit", fiber.MIMEApplicationForm, "title=Edited&content=Edited+body", fiber.StatusOK},
+		{"patch blog", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"title":"Patched"}`, fiber.StatusOK},
+		{"change visibility", http.MethodPost, "/blogs/{id}/visibility", "", "", fiber.StatusOK},
+		{"update status", http.MethodPut, "/blogs/{id}/status", fiber.MIMEApplicationJSON, `{"status":"archived"}`, fiber.StatusOK},
+		{"restore revision", http.MethodPost, "/blogs/{id}/revisions/1/restore", "", "", fiber.StatusOK},
+		// Uploads get as far as looking for the file
+		{"upload blog image", http.MethodPost, "/blogs/{id}/main-image", "", "", fiber.StatusBadRequest},
+		{"edit profile", http.MethodPut, "/users/edit", fiber.MIMEApplicationForm, "name=Edited", fiber.StatusOK},
+		{"upload profile image", http.MethodPost, "/users/profile-image", "", "", fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		blog := createTestBlog(t, owner, "Versioned post", models.BlogStatusDraft)
+		path := strings.ReplaceAll(tt.path, "{id}", blog.ID.String())
+
+		req := testRequest(tt.method, path, tt.contentType, tt.body, 0)
+		if status, body := doTestRequest(t, app, req); status != fiber.StatusPreconditionRequired {
+			t.Errorf("%s without If-Match: status %d, want 428: %s", tt.name, status, body)
+		}
+
+		req = testRequest(tt.method, path, tt.contentType, tt.body, 0)
+		req.Header.Set(fiber.HeaderIfMatch, versionETag(1000))
+		if status, body := doTestRequest(t, app, req); status != fiber.StatusPreconditionFailed {
+			t.Errorf("%s with a stale If-Match: status %d, want 412: %s", tt.name, status, body)
+		}
+
+		req = testRequest(tt.method, path, tt.contentType, tt.body, 0)
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		if status, body := doTestRequest(t, app, req); status != tt.status {
+			t.Errorf("%s with If-Match: *: status %d, want %d: %s", tt.name, status, tt.status, body)
+		}
+	}
+
+	// The profile form may send the version instead of the header
+	var user models.User
+	database.DB.First(&user, "id = ?", owner.ID)
+	form := "surname=Edited&version=" + strconv.Itoa(user.Version)
+	if status, body := doTestRequest(t, app, testRequest(http.MethodPut, "/users/edit", fiber.MIMEApplicationForm, form, 0)); status != fiber.StatusOK {
+		t.Errorf("edit profile with a version field: status %d, want 200: %s", status, body)
+	}
+}
+
+// TestBlogWritesRequireIfMatch runs without Postgres on the mocked post
+func TestBlogWritesRequireIfMatch(t *testing.T) {
+	tests := []struct {
+		name, method, path, contentType, body string
+	}{
+		{"edit", http.MethodPost, "/blogs/{id}/edit", fiber.MIMEApplicationForm, "title=Edited&content=Edited+body"},
+		{"patch", http.MethodPatch, "/blogs/{id}", fiber.MIMEApplicationJSON, `{"title":"Patched"}`},
+		{"change visibility", http.MethodPost, "/blogs/{id}/visibility", "", ""},
+		{"update status", http.MethodPut, "/blogs/{id}/status", fiber.MIMEApplicationJSON, `{"status":"archived"}`},
+		{"upload image", http.MethodPost, "/blogs/{id}/main-image", "", ""},
+	}
+
+	for _, tt := range tests {
+		mockBlogDB(t)
+		path := strings.ReplaceAll(tt.path, "{id}", stubBlogID)
+		status, body := doTestRequest(t, newActorApp(stubActors["owner"]), testRequest(tt.method, path, tt.contentType, tt.body, 0))
+		if status != fiber.StatusPreconditionRequired {
+			t.Errorf("%s without If-Match: status %d, want 428: %s", tt.name, status, body)
+		}
+	}
+}
+
+func TestIfMatchUsesStrongComparison(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{`"2", "3"`, true},
+		{"*", true},
+		{`"2"`, false},
+		{`W/"3"`, false},
+		{`3`, false},
+	}
+
+	app := fiber.New()
+	app.Put("/", func(c *fiber.Ctx) error {
+		if ifMatchSatisfied(c, 3) {
+			return c.SendStatus(fiber.StatusOK)
+		}
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	})
+	for _, tt := range tests {
+		req := testRequest(http.MethodPut, "/", "", "", 0)
+		req.Header.Set(fiber.HeaderIfMatch, tt.header)
+		status, _ := doTestRequest(t, app, req)
+		if got := status == fiber.StatusOK; got != tt.want {
+			t.Errorf("If-Match %s against version 3: satisfied %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
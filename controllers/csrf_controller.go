@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/utils"
+)
+
+// csrfTokenTTL matches the refresh token so the CSRF cookie outlives every auth cookie
+const csrfTokenTTL = refreshTokenTTL
+
+// GetCSRFToken returns the current CSRF token, issuing one if the cookie is missing.
+// Frontends on another origin can't read the cookie and use this instead.
+func GetCSRFToken(c *fiber.Ctx) error {
+	token := c.Cookies(helpers.CSRFCookieName)
+	if token == "" {
+		token = setCSRFCookie(c)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"csrf_token": token})
+}
+
+// setCSRFCookie issues a fresh double-submit token. It is rotated on every login.
+func setCSRFCookie(c *fiber.Ctx) string {
+	return writeCSRFCookie(c, utils.GenerateToken())
+}
+
+// renewCSRFCookie extends the CSRF cookie along with a refreshed session, so it doesn't
+// expire before the refresh token. The token itself is kept, so requests already sent
+// with it still pass.
+func renewCSRFCookie(c *fiber.Ctx) string {
+	token := c.Cookies(helpers.CSRFCookieName)
+	if token == "" {
+		return setCSRFCookie(c)
+	}
+	return writeCSRFCookie(c, token)
+}
+
+func writeCSRFCookie(c *fiber.Ctx, token string) string {
+	// Not HTTPOnly: the frontend has to read it and send it back in the X-CSRF-Token header
+	c.Cookie(&fiber.Cookie{
+		Name:     helpers.CSRFCookieName,
+		Value:    token,
+		Expires:  time.Now().Add(csrfTokenTTL),
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/",
+	})
+
+	return token
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+)
+
+func TestRenewCSRFCookieKeepsTheToken(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.SendString(renewCSRFCookie(c))
+	})
+
+	for _, current := range []string{"csrf-value", ""} {
+		req := httptest.NewRequest(fiber.MethodPost, "/", nil)
+		if current != "" {
+			req.Header.Set(fiber.HeaderCookie, helpers.CSRFCookieName+"="+current)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var renewed *http.Cookie
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == helpers.CSRFCookieName {
+				renewed = cookie
+			}
+		}
+		switch {
+		case renewed == nil || renewed.Value == "":
+			t.Errorf("cookie %q: no csrf_token cookie issued", current)
+		case current != "" && renewed.Value != current:
+			t.Errorf("cookie %q: renewed as %q, want the same token", current, renewed.Value)
+		case renewed.Expires.IsZero():
+			t.Errorf("cookie %q: renewed without an expiry", current)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/tokens"
+)
+
+// GetJWKS publishes the public keys used to sign tokens. Retired keys stay listed
+// until they are removed from JWT_KEYS, so tokens signed before a rotation still verify.
+func GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(tokens.PublicJWKS())
+}
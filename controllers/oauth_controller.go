@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/oauth"
+	"github.com/nurullahgd/main-blog-backend/tokens"
+	"github.com/nurullahgd/main-blog-backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	oauthStateTTL     = 10 * time.Minute
+	oauthModeLogin    = "login"
+	oauthModeLink     = "link"
+	maxUsernameLength = 30
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// GetOAuthProviders lists the configured identity providers
+func GetOAuthProviders(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"providers": oauth.Names()})
+}
+
+// OAuthLogin redirects to the provider to sign in or sign up
+func OAuthLogin(c *fiber.Ctx) error {
+	return redirectToProvider(c, oauthModeLogin, "")
+}
+
+// LinkIdentity redirects to the provider to link an external account to the logged in user
+func LinkIdentity(c *fiber.Ctx) error {
+	return redirectToProvider(c, oauthModeLink, c.Locals("userID").(string))
+}
+
+// OAuthCallback finishes the authorization code flow for both login and linking
+func OAuthCallback(c *fiber.Ctx) error {
+	provider, ok := oauth.Get(c.Params("provider"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown provider"})
+	}
+
+	state, err := parseOAuthState(c.Cookies("oauth_state"), provider.Name)
+	clearOAuthStateCookie(c)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		return redirectOAuthResult(c, url.Values{"error": {"invalid_state"}})
+	}
+	if c.Query("error") != "" || c.Query("code") == "" {
+		return redirectOAuthResult(c, url.Values{"error": {"access_denied"}})
+	}
+
+	accessToken, err := provider.Exchange(c.Context(), c.Query("code"), state.Verifier)
+	if err != nil {
+		return redirectOAuthResult(c, url.Values{"error": {"exchange_failed"}})
+	}
+	identity, err := provider.FetchIdentity(c.Context(), accessToken)
+	if err != nil {
+		return redirectOAuthResult(c, url.Values{"error": {"profile_failed"}})
+	}
+
+	var existing models.UserIdentity
+	found := database.DB.Where("provider = ? AND subject = ?", provider.Name, identity.Subject).First(&existing).Error == nil
+
+	if state.Mode == oauthModeLink {
+		if found && existing.UserID != state.UserID {
+			return redirectOAuthResult(c, url.Values{"error": {"identity_in_use"}})
+		}
+		if !found {
+			link := models.UserIdentity{UserID: state.UserID, Provider: provider.Name, Subject: identity.Subject, Email: identity.Email}
+			if err := database.DB.Create(&link).Error; err != nil {
+				return redirectOAuthResult(c, url.Values{"error": {"link_failed"}})
+			}
+		}
+		return redirectOAuthResult(c, url.Values{"linked": {provider.Name}})
+	}
+
+	var user models.User
+	if found {
+		if err := database.DB.First(&user, "id = ?", existing.UserID).Error; err != nil {
+			return redirectOAuthResult(c, url.Values{"error": {"user_not_found"}})
+		}
+	} else {
+		user, err = createUserFromIdentity(provider.Name, identity)
+		if errors.Is(err, errEmailInUse) {
+			// Never attach an external account to an existing user without a logged in link request
+			return redirectOAuthResult(c, url.Values{"error": {"email_in_use"}})
+		}
+		if err != nil {
+			return redirectOAuthResult(c, url.Values{"error": {"signup_failed"}})
+		}
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := generateTwoFactorChallenge(user.ID.String(), challengePurposeUser)
+		if err != nil {
+			return redirectOAuthResult(c, url.Values{"error": {"login_failed"}})
+		}
+		setTwoFactorChallengeCookie(c, challenge)
+		return redirectOAuthResult(c, url.Values{"two_factor": {"required"}})
+	}
+
+	if err := startSession(c, user); err != nil {
+		return redirectOAuthResult(c, url.Values{"error": {"login_failed"}})
+	}
+	return redirectOAuthResult(c, url.Values{"status": {"ok"}})
+}
+
+func GetIdentities(c *fiber.Ctx) error {
+	var identities []models.UserIdentity
+	database.DB.Where("user_id = ?", c.Locals("userID")).Order("created_at").Find(&identities)
+
+	response := []models.UserIdentityResponse{}
+	for _, identity := range identities {
+		response = append(response, models.UserIdentityResponse{
+			ID:        identity.ID.String(),
+			Provider:  identity.Provider,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// UnlinkIdentity removes a linked identity. Accounts created through a provider
+// can still get in afterwards with the forgot-password flow on their email.
+func UnlinkIdentity(c *fiber.Ctx) error {
+	result := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), c.Locals("userID")).Delete(&models.UserIdentity{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Identity not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Identity unlinked successfully"})
+}
+
+var errEmailInUse = errors.New("email already registered")
+
+// createUserFromIdentity signs up a new user for a first-time external login
+func createUserFromIdentity(providerName string, identity oauth.Identity) (models.User, error) {
+	var user models.User
+
+	if identity.Email == "" {
+		return user, errors.New("provider returned no email")
+	}
+	var count int64
+	database.DB.Unscoped().Model(&models.User{}).Where("email = ?", identity.Email).Count(&count)
+	if count > 0 {
+		return user, errEmailInUse
+	}
+
+	// The account gets a random password nobody knows; a real one can be set with the reset flow
+	hashedPassword, err := helpers.HashPassword(utils.GenerateToken())
+	if err != nil {
+		return user, err
+	}
+
+	name, surname := splitFullName(identity.Name)
+	user = models.User{
+		Name:          name,
+		Surname:       surname,
+		Username:      uniqueUsername(identity),
+		Email:         identity.Email,
+		Password:      []byte(hashedPassword),
+		EmailVerified: identity.EmailVerified,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID.String(),
+			Provider: providerName,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}).Error
+	})
+	return user, err
+}
+
+// uniqueUsername derives a username from the identity and appends a counter until it is free
+func uniqueUsername(identity oauth.Identity) string {
+	base := identity.Username
+	if base == "" {
+		base = strings.Split(identity.Email, "@")[0]
+	}
+	base = usernameInvalidChars.ReplaceAllString(strings.ToLower(base), "_")
+	base = strings.Trim(base, "_")
+	if len(base) > maxUsernameLength-5 {
+		base = base[:maxUsernameLength-5]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for counter := 1; ; counter++ {
+		var count int64
+		database.DB.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count)
+		if count == 0 {
+			return username
+		}
+		if counter > 100 {
+			// Heavily taken base name, fall back to a random suffix
+			return fmt.Sprintf("%s_%s", base, strings.ToLower(utils.GenerateToken()[:6]))
+		}
+		username = fmt.Sprintf("%s%d", base, counter)
+	}
+}
+
+func splitFullName(fullName string) (string, string) {
+	fullName = strings.TrimSpace(fullName)
+	i := strings.LastIndex(fullName, " ")
+	if i < 0 {
+		return fullName, ""
+	}
+	return fullName[:i], fullName[i+1:]
+}
+
+// oauthState is kept in a signed cookie between the redirect and the callback
+type oauthState struct {
+	State    string
+	Verifier string
+	Mode     string
+	UserID   string
+}
+
+func redirectToProvider(c *fiber.Ctx, mode, userID string) error {
+	provider, ok := oauth.Get(c.Params("provider"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown provider"})
+	}
+
+	state := oauth.NewState()
+	verifier, challenge := oauth.NewPKCE()
+
+	authURL, err := provider.AuthCodeURL(c.Context(), state, challenge)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Identity provider unavailable"})
+	}
+
+	tokenString, err := tokens.Sign(jwt.MapClaims{
+		"provider": provider.Name,
+		"state":    state,
+		"verifier": verifier,
+		"mode":     mode,
+		"user_id":  userID,
+		"purpose":  "oauth_state",
+		"iss":      tokens.Issuer,
+		"aud":      tokens.AudienceOAuthState,
+		"exp":      time.Now().Add(oauthStateTTL).Unix(),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "oauth_state",
+		Value:    tokenString,
+		Expires:  time.Now().Add(oauthStateTTL),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax", // must survive the top-level redirect back from the provider
+		Path:     "/api/auth",
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+func parseOAuthState(tokenString, providerName string) (oauthState, error) {
+	claims := jwt.MapClaims{}
+	parsedToken, err := tokens.Parse(tokenString, claims, jwt.WithAudience(tokens.AudienceOAuthState), jwt.WithIssuer(tokens.Issuer))
+	if err != nil || !parsedToken.Valid || claims["purpose"] != "oauth_state" || claims["provider"] != providerName {
+		return oauthState{}, errors.New("invalid oauth state")
+	}
+
+	state := oauthState{}
+	state.State, _ = claims["state"].(string)
+	state.Verifier, _ = claims["verifier"].(string)
+	state.Mode, _ = claims["mode"].(string)
+	state.UserID, _ = claims["user_id"].(string)
+	if state.State == "" || state.Verifier == "" {
+		return oauthState{}, errors.New("invalid oauth state")
+	}
+	return state, nil
+}
+
+func clearOAuthStateCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "oauth_state",
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+		Path:     "/api/auth",
+	})
+}
+
+// redirectOAuthResult sends the browser back to the frontend with the outcome in the query string
+func redirectOAuthResult(c *fiber.Ctx, params url.Values) error {
+	return c.Redirect(helpers.FrontendURL()+"/oauth/callback?"+params.Encode(), fiber.StatusFound)
+}
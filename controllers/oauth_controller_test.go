@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/oauth"
+	"github.com/nurullahgd/main-blog-backend/tokens"
+)
+
+func newOAuthTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	// The token endpoint rejects every code, so a callback that gets past the state
+	// check ends in exchange_failed
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(provider.Close)
+
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_KEYS", "")
+	t.Setenv("FRONTEND_URL", "http://frontend.test")
+	t.Setenv("OAUTH_PROVIDERS", "fake,other")
+	for _, name := range []string{"FAKE", "OTHER"} {
+		t.Setenv("OAUTH_"+name+"_TYPE", oauth.TypeOIDC)
+		t.Setenv("OAUTH_"+name+"_CLIENT_ID", "client")
+		t.Setenv("OAUTH_"+name+"_AUTH_URL", provider.URL+"/authorize")
+		t.Setenv("OAUTH_"+name+"_TOKEN_URL", provider.URL+"/token")
+		t.Setenv("OAUTH_"+name+"_USERINFO_URL", provider.URL+"/userinfo")
+	}
+	if err := tokens.Init(); err != nil {
+		t.Fatalf("tokens.Init: %v", err)
+	}
+	if err := oauth.Init(); err != nil {
+		t.Fatalf("oauth.Init: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/api/auth/:provider/login", OAuthLogin)
+	app.Get("/api/auth/:provider/callback", OAuthCallback)
+	return app
+}
+
+// startOAuthLogin returns the state cookie and the state sent to the provider
+func startOAuthLogin(t *testing.T, app *fiber.App, provider string) (*http.Cookie, string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/auth/"+provider+"/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusFound {
+		t.Fatalf("login status = %d, want 302", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "oauth_state" {
+			return cookie, location.Query().Get("state")
+		}
+	}
+	t.Fatal("login didn't set the oauth_state cookie")
+	return nil, ""
+}
+
+func callbackError(t *testing.T, app *fiber.App, provider, state string, cookie *http.Cookie) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/"+provider+"/callback?code=code&state="+url.QueryEscape(state), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err := url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	if err != nil || resp.StatusCode != fiber.StatusFound {
+		t.Fatalf("callback status = %d, location %q", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+	return location.Query().Get("error")
+}
+
+func TestOAuthCallbackRejectsStateMismatch(t *testing.T) {
+	app := newOAuthTestApp(t)
+	cookie, state := startOAuthLogin(t, app, "fake")
+	if state == "" {
+		t.Fatal("login redirect has no state")
+	}
+
+	if got := callbackError(t, app, "fake", "forged-state", cookie); got != "invalid_state" {
+		t.Errorf("wrong state: error = %q, want invalid_state", got)
+	}
+	if got := callbackError(t, app, "fake", state, nil); got != "invalid_state" {
+		t.Errorf("missing cookie: error = %q, want invalid_state", got)
+	}
+	if got := callbackError(t, app, "other", state, cookie); got != "invalid_state" {
+		t.Errorf("cookie from another provider: error = %q, want invalid_state", got)
+	}
+
+	if got := callbackError(t, app, "fake", state, cookie); got != "exchange_failed" {
+		t.Errorf("matching state: error = %q, want the flow to reach the token exchange", got)
+	}
+
+	tampered := *cookie
+	tampered.Value += "x"
+	if got := callbackError(t, app, "fake", state, &tampered); got != "invalid_state" {
+		t.Errorf("tampered cookie: error = %q, want invalid_state", got)
+	}
+}
+
+func TestLoginTwoFactorReadsTheChallengeCookie(t *testing.T) {
+	newOAuthTestApp(t)
+	// Only a challenge that parses gets as far as the database
+	useUnreachableDB(t)
+	challenge, err := generateTwoFactorChallenge("10000000-0000-0000-0000-000000000002", challengePurposeUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Post("/api/users/login/2fa", LoginTwoFactor)
+	app.Get("/challenge", func(c *fiber.Ctx) error {
+		setTwoFactorChallengeCookie(c, challenge)
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/challenge", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == twoFactorChallengeCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.Path != "/api/users/login/2fa" {
+		t.Fatalf("challenge cookie = %+v, want an httpOnly cookie scoped to the 2FA login", cookie)
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"no challenge", nil, fiber.StatusUnauthorized},
+		{"challenge cookie", cookie, fiber.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/users/login/2fa", strings.NewReader(`{"code":"123456"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if tt.cookie != nil {
+			req.AddCookie(tt.cookie)
+		}
+		status, body := doTestRequest(t, app, req)
+		if status != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, status, tt.want, body)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/mailer"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	minPasswordLength = 8
+	passwordResetTTL  = time.Hour
+)
+
+var errInvalidResetToken = errors.New("invalid reset token")
+
+// ChangePassword sets a new password after checking the current one and
+// revokes every other session and all personal access tokens of the user
+func ChangePassword(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input models.UserPasswordChange
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := helpers.VerifyPassword(input.CurrentPassword, string(user.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Current password is incorrect"})
+	}
+	if len(input.Password) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Password must be at least %d characters", minPasswordLength)})
+	}
+
+	hashedPassword, err := helpers.HashPassword(input.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", []byte(hashedPassword)).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", user.ID, c.Locals("sessionID")).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return revokeUserAccessTokens(tx, user.ID.String())
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update password"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password changed successfully"})
+}
+
+// ForgotPassword emails a reset link. It always answers the same way so it
+// can't be used to find out which emails are registered.
+func ForgotPassword(c *fiber.Ctx) error {
+	var input models.PasswordForgot
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	response := fiber.Map{"message": "If an account with that email exists, a reset link has been sent"}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", strings.TrimSpace(input.Email)).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+
+	// Everything after the lookup runs in the background, so a registered email is
+	// answered as fast as an unknown one
+	go sendPasswordResetEmail(user)
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// sendPasswordResetEmail replaces the user's reset link with a new one and mails it
+func sendPasswordResetEmail(user models.User) {
+	token := utils.GenerateToken()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the most recent reset link stays valid
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID.String(),
+			TokenHash: helpers.HashToken(token),
+			ExpiresAt: time.Now().Add(passwordResetTTL),
+		}).Error
+	})
+	if err != nil {
+		log.Println("Failed to create password reset token:", err)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %d minutes.\n\n%s/reset-password?token=%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+			user.Name, int(passwordResetTTL.Minutes()), helpers.FrontendURL(), token),
+	}
+	if err := mailer.Send(msg); err != nil {
+		log.Println("Failed to send password reset email:", err)
+	}
+}
+
+// ResetPassword sets a new password with a reset token and revokes all sessions and
+// personal access tokens
+func ResetPassword(c *fiber.Ctx) error {
+	var input models.PasswordReset
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if len(input.Password) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Password must be at least %d characters", minPasswordLength)})
+	}
+
+	hashedPassword, err := helpers.HashPassword(input.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
+	}
+
+	now := time.Now()
+	var resetUserID string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var resetToken models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", helpers.HashToken(input.Token), now).
+			First(&resetToken).Error; err != nil {
+			return errInvalidResetToken
+		}
+
+		resetUserID = resetToken.UserID
+
+		if err := tx.Model(&resetToken).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Update("password", []byte(hashedPassword)).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, resetToken.UserID)
+	})
+	if err == errInvalidResetToken {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired reset token"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reset password"})
+	}
+
+	// Proving control of the email is enough to lift a lockout
+	helpers.ResetLoginThrottle(helpers.AccountThrottleKey(models.OwnerTypeUser, resetUserID))
+
+	clearSessionCookies(c)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password reset successfully"})
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/mailer"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestForgotPasswordAnswersBeforeCreatingTheLink(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousDB, previousMailer := database.DB, mailer.Default
+	sent := mailer.NewLogMailer()
+	database.DB, mailer.Default = gormDB, sent
+	t.Cleanup(func() {
+		database.DB, mailer.Default = previousDB, previousMailer
+		db.Close()
+	})
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "email", "name"}).AddRow(stubOwnerID, "owner@example.com", "Owner"),
+	)
+	// Creating the link is slow; an unknown email would be answered right after the lookup
+	const slow = 500 * time.Millisecond
+	mock.ExpectBegin().WillDelayFor(slow)
+	mock.ExpectExec(`DELETE FROM "password_reset_tokens"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "password_reset_tokens"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(stubBlogID))
+	mock.ExpectCommit()
+
+	app := fiber.New()
+	app.Post("/password/forgot", ForgotPassword)
+	start := time.Now()
+	status, body := doTestRequest(t, app, testRequest(http.MethodPost, "/password/forgot", fiber.MIMEApplicationJSON, `{"email":"owner@example.com"}`, 0))
+	if status != fiber.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	if elapsed := time.Since(start); elapsed >= slow {
+		t.Errorf("answered after %v, want the link created in the background", elapsed)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); len(sent.Messages()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	messages := sent.Messages()
+	if len(messages) != 1 || messages[0].To != "owner@example.com" || !strings.Contains(messages[0].Body, "/reset-password?token=") {
+		t.Fatalf("sent %+v, want one reset link to the owner", messages)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultRevisionLimit = 50
+	maxRevisionLimit     = 1000
+)
+
+// GetBlogRevisions lists a post's revisions, newest first
+func GetBlogRevisions(c *fiber.Ctx) error {
+	blog, fiberErr := findEditableBlog(c)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+
+	var revisions []models.BlogRevision
+	database.DB.Where("blog_id = ?", blog.ID).Order("number DESC").Find(&revisions)
+
+	response := []models.BlogRevisionSummary{}
+	for _, revision := range revisions {
+		response = append(response, models.BlogRevisionSummary{
+			Number:       revision.Number,
+			Title:        revision.Title,
+			AuthorID:     revision.AuthorID,
+			AuthorType:   revision.AuthorType,
+			RestoredFrom: revision.RestoredFrom,
+			CreatedAt:    revision.CreatedAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func GetBlogRevision(c *fiber.Ctx) error {
+	blog, fiberErr := findEditableBlog(c)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+
+	revision, ok := findRevision(blog, c.Params("number"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(revision)
+}
+
+// DiffBlogRevisions compares two revisions field by field. mode is "line" (default) or "word".
+func DiffBlogRevisions(c *fiber.Ctx) error {
+	blog, fiberErr := findEditableBlog(c)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+
+	from, ok := findRevision(blog, c.Query("from"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision 'from' not found"})
+	}
+	to, ok := findRevision(blog, c.Query("to"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision 'to' not found"})
+	}
+
+	mode := c.Query("mode", "line")
+	var diff func(a, b string) []helpers.DiffOp
+	switch mode {
+	case "line":
+		diff = helpers.DiffLines
+	case "word":
+		diff = helpers.DiffWords
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Mode must be line or word"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"from": from.Number,
+		"to":   to.Number,
+		"mode": mode,
+		"fields": fiber.Map{
+			"title":          diff(from.Title, to.Title),
+			"summary":        diff(from.Summary, to.Summary),
+			"category":       diff(from.Category, to.Category),
+			"content":        diff(from.Content, to.Content),
+			"content_format": diff(from.ContentFormat, to.ContentFormat),
+		},
+	})
+}
+
+// RestoreBlogRevision copies an old revision back onto the post. The restore is
+// itself saved as a new revision, so it can be undone the same way.
+func RestoreBlogRevision(c *fiber.Ctx) error {
+	blog, fiberErr := findEditableBlog(c)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+
+	revision, ok := findRevision(blog, c.Params("number"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+	}
+	if handled, err := checkIfMatch(c, blog.Version); handled {
+		return err
+	}
+
+	blog.Title = revision.Title
+	blog.Content = revision.Content
+	blog.ContentFormat = revision.ContentFormat
+	if err := renderBlogContent(&blog); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore revision"})
+	}
+	blog.Summary = revision.Summary
+	// Revisions keep the category's slug; a category deleted since then leaves the post uncategorized
+	categoryID, err := resolveCategory(revision.Category)
+	if err != nil && !errors.Is(err, errUnknownCategory) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore revision"})
+	}
+	blog.CategoryID = categoryID
+
+	actor := policy.ActorFromContext(c)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &blog, &blog.Version); err != nil {
+			return err
+		}
+		return recordRevision(tx, blog, actor, &revision.Number)
+	})
+	if errors.Is(err, errVersionConflict) {
+		return versionConflict(c, &models.Blog{}, blog.ID)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore revision"})
+	}
+
+	c.Set(fiber.HeaderETag, versionETag(blog.Version))
+	loadBlogDetail(&blog)
+	return c.Status(fiber.StatusOK).JSON(toBlogResponse(blog))
+}
+
+// UpdateRevisionRetention sets how many revisions the post keeps and prunes older ones right away
+func UpdateRevisionRetention(c *fiber.Ctx) error {
+	var blog models.Blog
+	if err := database.DB.First(&blog, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blog not found"})
+	}
+	if !policy.CanManageBlogSettings(policy.ActorFromContext(c), blog) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to change this blog's settings"})
+	}
+
+	var input models.BlogRevisionRetention
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Limit < 0 || input.Limit > maxRevisionLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Limit must be between 0 and " + strconv.Itoa(maxRevisionLimit)})
+	}
+
+	blog.RevisionLimit = input.Limit
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Bump the version too, so a concurrent full save can't write back the old limit
+		err := tx.Model(&blog).UpdateColumns(map[string]interface{}{
+			"revision_limit": input.Limit,
+			"version":        gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return pruneRevisions(tx, blog)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update revision retention"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"revision_limit": revisionLimit(blog)})
+}
+
+// findEditableBlog loads the post from :id and checks the caller may edit it
+func findEditableBlog(c *fiber.Ctx) (models.Blog, *fiber.Error) {
+	var blog models.Blog
+	if err := database.DB.First(&blog, "id = ?", c.Params("id")).Error; err != nil {
+		return blog, fiber.NewError(fiber.StatusNotFound, "Blog not found")
+	}
+	if !policy.CanEditBlog(policy.ActorFromContext(c), blog) {
+		return blog, fiber.NewError(fiber.StatusForbidden, "You are not authorized to edit this blog")
+	}
+	return blog, nil
+}
+
+func findRevision(blog models.Blog, number string) (models.BlogRevision, bool) {
+	var revision models.BlogRevision
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return revision, false
+	}
+	err = database.DB.Where("blog_id = ? AND number = ?", blog.ID, n).First(&revision).Error
+	return revision, err == nil
+}
+
+// recordRevision snapshots the post's content as its next revision and prunes
+// revisions beyond the retention limit. It must run in the transaction that saved the post.
+func recordRevision(tx *gorm.DB, blog models.Blog, actor policy.Actor, restoredFrom *int) error {
+	// Lock the post so concurrent saves get consecutive revision numbers
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Blog{}, "id = ?", blog.ID).Error; err != nil {
+		return err
+	}
+
+	var last int
+	if err := tx.Model(&models.BlogRevision{}).Where("blog_id = ?", blog.ID).Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+	category, err := categorySlug(tx, blog.CategoryID)
+	if err != nil {
+		return err
+	}
+
+	revision := models.BlogRevision{
+		BlogID:        blog.ID,
+		Number:        last + 1,
+		Title:         blog.Title,
+		Content:       blog.Content,
+		ContentFormat: blog.ContentFormat,
+		Summary:       blog.Summary,
+		Category:      category,
+		AuthorID:      actor.UserID,
+		AuthorType:    models.OwnerTypeUser,
+		RestoredFrom:  restoredFrom,
+	}
+	if actor.UserID == "" {
+		revision.AuthorID = actor.AdminID
+		revision.AuthorType = models.OwnerTypeAdmin
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return err
+	}
+
+	return pruneRevisions(tx, blog)
+}
+
+// pruneRevisions deletes all but the newest revisionLimit revisions of the post
+func pruneRevisions(tx *gorm.DB, blog models.Blog) error {
+	keep := tx.Model(&models.BlogRevision{}).
+		Select("number").
+		Where("blog_id = ?", blog.ID).
+		Order("number DESC").
+		Limit(revisionLimit(blog))
+
+	return tx.Where("blog_id = ? AND number NOT IN (?)", blog.ID, keep).Delete(&models.BlogRevision{}).Error
+}
+
+// revisionLimit returns the post's retention limit, falling back to BLOG_REVISION_LIMIT
+func revisionLimit(blog models.Blog) int {
+	if blog.RevisionLimit > 0 {
+		return blog.RevisionLimit
+	}
+	if limit, err := strconv.Atoi(os.Getenv("BLOG_REVISION_LIMIT")); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultRevisionLimit
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z_]{1,20}$`)
+
+func GetRoles(c *fiber.Ctx) error {
+	var rows []models.RolePermission
+	database.DB.Order("role, permission").Find(&rows)
+
+	// Group permissions by role, super_admin first
+	response := []models.RoleResponse{{Role: models.RoleSuperAdmin, Permissions: models.AllPermissions}}
+	index := map[string]int{}
+	for _, row := range rows {
+		i, ok := index[row.Role]
+		if !ok {
+			response = append(response, models.RoleResponse{Role: row.Role, Permissions: []string{}})
+			i = len(response) - 1
+			index[row.Role] = i
+		}
+		response[i].Permissions = append(response[i].Permissions, row.Permission)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"roles":       response,
+		"permissions": models.AllPermissions,
+	})
+}
+
+func UpdateRolePermissions(c *fiber.Ctx) error {
+	role := c.Params("role")
+	if role == models.RoleSuperAdmin {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "super_admin permissions cannot be changed"})
+	}
+	if !roleNamePattern.MatchString(role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid role name"})
+	}
+
+	var input models.RolePermissionsUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	// A role only exists through its permission rows, so an empty set would delete it
+	// while admins are still assigned to it
+	if len(input.Permissions) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A role needs at least one permission"})
+	}
+
+	known := map[string]bool{}
+	for _, permission := range models.AllPermissions {
+		known[permission] = true
+	}
+	seen := map[string]bool{}
+	var rows []models.RolePermission
+	for _, permission := range input.Permissions {
+		if !known[permission] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown permission: " + permission})
+		}
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+		rows = append(rows, models.RolePermission{Role: role, Permission: permission})
+	}
+
+	// Replace the whole permission set atomically
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", role).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update role"})
+	}
+
+	permissions, _ := helpers.RolePermissions(role)
+	return c.Status(fiber.StatusOK).JSON(models.RoleResponse{Role: role, Permissions: permissions})
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUpdateRolePermissionsRejectsAnEmptySet(t *testing.T) {
+	// The request is rejected before the database is touched
+	useUnreachableDB(t)
+	app := fiber.New()
+	app.Put("/roles/:role", UpdateRolePermissions)
+
+	for _, body := range []string{`{"permissions":[]}`, `{}`} {
+		status, resp := doTestRequest(t, app, testRequest(http.MethodPut, "/roles/editor", fiber.MIMEApplicationJSON, body, 0))
+		if status != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", body, status, resp)
+		}
+	}
+}
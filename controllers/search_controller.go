@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+)
+
+// searchListOptions are the sort keys accepted by search. Results are ranked by default.
+var searchListOptions = helpers.ListOptions{
+	Table: "blogs",
+	Sorts: map[string]string{
+		"rank":         "blogs.rank",
+		"created_at":   "blogs.created_at",
+		"published_at": "COALESCE(blogs.published_at, blogs.created_at)",
+		"updated_at":   "blogs.updated_at",
+	},
+	DefaultSort: "-rank",
+}
+
+// Snippet highlights are marked with control characters by ts_headline, so the text
+// can be escaped before they become <mark> tags
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+// snippetOptions configures ts_headline
+var snippetOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" … "`, snippetStart, snippetStop)
+
+// searchResult is a matching post with its rank
+type searchResult struct {
+	models.Blog
+	Rank float64
+}
+
+// SearchBlogs finds posts matching ?q=, which takes web search syntax: quoted phrases,
+// OR and -word. Anonymous readers only find published posts; signed in users also find
+// the posts they can edit. ?lang= limits results to posts in one language, and the usual
+// blog filters, paging, ?fields= and ?include= apply.
+func SearchBlogs(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Search query is required"})
+	}
+
+	params, err := helpers.ParseListParams(c, searchListOptions)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	view, err := parseBlogView(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	languages := models.SearchLanguages
+	if lang := c.Query("lang"); lang != "" {
+		language, err := blogLanguage(lang)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		languages = []string{language}
+	}
+	match, args := searchMatch(languages, q)
+
+	// Ranking happens in a subquery so the rank can be sorted and paged on like a column
+	ranked := database.DB.Model(&models.Blog{}).
+		Select("blogs.*, ts_rank(blogs.search_vector, websearch_to_tsquery(blogs.language::regconfig, ?)) AS rank", q).
+		Where(match, args...)
+	ranked = policy.ListableBlogs(policy.ActorFromContext(c), ranked)
+
+	query, fiberErr := filterBlogs(c, database.DB.Table("(?) AS blogs", ranked))
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+	page, err := helpers.Paginate(query.Omit(view.omittedColumns()...), params, func(result searchResult) (time.Time, string) {
+		return blogCursorKey(result.Blog)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search blogs"})
+	}
+
+	blogs := make([]models.Blog, len(page.Data))
+	for i, result := range page.Data {
+		blogs[i] = result.Blog
+	}
+	view.load(blogs)
+	for i := range page.Data {
+		page.Data[i].Blog = blogs[i]
+	}
+	snippets := searchSnippets(blogs, q)
+
+	return c.Status(fiber.StatusOK).JSON(helpers.MapListPage(page, func(result searchResult) interface{} {
+		snippet := snippets[result.ID]
+		switch card := view.card(result.Blog).(type) {
+		case models.BlogCard:
+			return models.BlogSearchResult{BlogCard: card, Snippet: snippet, Rank: result.Rank}
+		case map[string]json.RawMessage:
+			card["snippet"], _ = json.Marshal(snippet)
+			card["rank"], _ = json.Marshal(result.Rank)
+			return card
+		default:
+			return card
+		}
+	}))
+}
+
+// searchMatch returns the condition matching q against posts in the given languages, each
+// post only in its own language. Every language gets its own arm with the language spelled
+// out, which lets Postgres use that language's partial GIN index; the languages come from
+// models.SearchLanguages, never from the request.
+func searchMatch(languages []string, q string) (string, []interface{}) {
+	parts := make([]string, len(languages))
+	args := make([]interface{}, len(languages))
+	for i, language := range languages {
+		parts[i] = fmt.Sprintf("(blogs.language = '%[1]s' AND blogs.search_vector @@ websearch_to_tsquery('%[1]s', ?))", language)
+		args[i] = q
+	}
+	return "(" + strings.Join(parts, " OR ") + ")", args
+}
+
+// searchSnippets highlights the matches in the content of each post, falling back to
+// its summary. Only the posts of the page are highlighted, since ts_headline is slow.
+func searchSnippets(blogs []models.Blog, q string) map[uuid.UUID]string {
+	snippets := map[uuid.UUID]string{}
+	if len(blogs) == 0 {
+		return snippets
+	}
+	ids := make([]uuid.UUID, len(blogs))
+	for i, blog := range blogs {
+		ids[i] = blog.ID
+	}
+
+	var rows []struct {
+		ID      uuid.UUID
+		Snippet string
+	}
+	database.DB.Table("blogs").
+		Select("id, ts_headline(language::regconfig, COALESCE(NULLIF(content_text, ''), summary), websearch_to_tsquery(language::regconfig, ?), ?) AS snippet", q, snippetOptions).
+		Where("id IN ?", ids).
+		Scan(&rows)
+
+	for _, row := range rows {
+		snippet := html.EscapeString(row.Snippet)
+		snippet = strings.ReplaceAll(snippet, snippetStart, "<mark>")
+		snippets[row.ID] = strings.ReplaceAll(snippet, snippetStop, "</mark>")
+	}
+	return snippets
+}
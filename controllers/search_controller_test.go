@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+func TestSearchMatchPairsEachLanguageWithItsPosts(t *testing.T) {
+	match, args := searchMatch([]string{"simple", "english"}, "go")
+	want := "((blogs.language = 'simple' AND blogs.search_vector @@ websearch_to_tsquery('simple', ?)) OR " +
+		"(blogs.language = 'english' AND blogs.search_vector @@ websearch_to_tsquery('english', ?)))"
+	if match != want {
+		t.Errorf("match = %s\nwant %s", match, want)
+	}
+	if len(args) != 2 || args[0] != "go" || args[1] != "go" {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestSearchMatchesPostsInTheirOwnLanguage(t *testing.T) {
+	openTestDB(t)
+	owner := createTestUser(t, "searcher")
+	word := "lang" + testSuffix()
+
+	// Stemmed in English, "running" matches "run"; as simple words they differ
+	english := createTestBlog(t, owner, "Running "+word, models.BlogStatusPublished)
+	database.DB.Model(&english).UpdateColumn("language", "english")
+	createTestBlog(t, owner, "Run "+word, models.BlogStatusPublished)
+
+	app := fiber.New()
+	app.Get("/blogs/search", SearchBlogs)
+	status, body := doTestRequest(t, app, testRequest(http.MethodGet, "/blogs/search?q="+url.QueryEscape("running "+word), "", "", 0))
+	if status != fiber.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+
+	var page struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Snippet string `json:"snippet"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].ID != english.ID.String() {
+		t.Fatalf("found %+v, want only the English post", page.Data)
+	}
+	if !strings.Contains(page.Data[0].Snippet, "<mark>") {
+		t.Errorf("snippet %q has no highlight", page.Data[0].Snippet)
+	}
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/tokens"
+	"github.com/nurullahgd/main-blog-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// RefreshSession exchanges the refresh_token cookie for a new access token and
+// a new refresh token. Presenting an already used refresh token revokes the
+// whole session, since it means the token was copied.
+func RefreshSession(c *fiber.Ctx) error {
+	rawToken := c.Cookies("refresh_token")
+	if rawToken == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "No refresh token provided"})
+	}
+
+	var session models.Session
+	newRefreshToken := utils.GenerateToken()
+	now := time.Now()
+	reused := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", helpers.HashToken(rawToken)).
+			First(&current).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&session, "id = ?", current.SessionID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+		if session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(current.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		if current.UsedAt != nil {
+			// Reuse of a rotated token: revoke the whole family. Returning nil
+			// commits the revocation; the caller is rejected below.
+			reused = true
+			return tx.Model(&session).Update("revoked_at", now).Error
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		next := models.RefreshToken{
+			SessionID: session.ID,
+			TokenHash: helpers.HashToken(newRefreshToken),
+			ExpiresAt: now.Add(refreshTokenTTL),
+		}
+		if err := tx.Create(&next).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"expires_at":   next.ExpiresAt,
+			"last_seen_at": now,
+			"ip_address":   c.IP(),
+		}).Error
+	})
+
+	if errors.Is(err, errInvalidRefreshToken) || (err == nil && reused) {
+		clearSessionCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired refresh token"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to refresh session"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", session.UserID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	accessToken, err := generateAccessToken(user, session.ID.String())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	setSessionCookies(c, accessToken, newRefreshToken, session.ExpiresAt)
+	renewCSRFCookie(c)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Session refreshed"})
+}
+
+func GetSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	currentID := c.Locals("sessionID")
+
+	var sessions []models.Session
+	database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions)
+
+	response := []models.SessionResponse{}
+	for _, session := range sessions {
+		response = append(response, models.SessionResponse{
+			ID:         session.ID.String(),
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			Current:    session.ID.String() == currentID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	sessionID := c.Params("id")
+
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Session not found"})
+	}
+
+	if sessionID == c.Locals("sessionID") {
+		clearSessionCookies(c)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions logs the user out everywhere, including the current session
+func RevokeAllSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeUserSessions(tx, userID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+	}
+	clearSessionCookies(c)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "All sessions revoked successfully"})
+}
+
+// RevokeUserSessionsFromAdmin locks a user out of every device immediately
+func RevokeUserSessionsFromAdmin(c *fiber.Ctx) error {
+	userID := c.Params("id")
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeUserSessions(tx, user.ID.String())
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User sessions revoked successfully"})
+}
+
+// startSession creates a new session for the user and sets the access, refresh and CSRF token cookies
+func startSession(c *fiber.Ctx, user models.User) error {
+	now := time.Now()
+	refreshToken := utils.GenerateToken()
+
+	session := models.Session{
+		UserID:     user.ID.String(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		IPAddress:  c.IP(),
+		ExpiresAt:  now.Add(refreshTokenTTL),
+		LastSeenAt: now,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.RefreshToken{
+			SessionID: session.ID,
+			TokenHash: helpers.HashToken(refreshToken),
+			ExpiresAt: session.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := generateAccessToken(user, session.ID.String())
+	if err != nil {
+		return err
+	}
+
+	setSessionCookies(c, accessToken, refreshToken, session.ExpiresAt)
+	setCSRFCookie(c)
+	return nil
+}
+
+// revokeSessionByRefreshToken revokes the session the given refresh token belongs to
+func revokeSessionByRefreshToken(rawToken string) error {
+	var token models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", helpers.HashToken(rawToken)).First(&token).Error; err != nil {
+		return err
+	}
+	return database.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", token.SessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeUserSessions revokes every active session and personal access token of the user
+func revokeUserSessions(tx *gorm.DB, userID string) error {
+	err := tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return revokeUserAccessTokens(tx, userID)
+}
+
+// revokeUserAccessTokens revokes the user's personal access tokens. They act for the
+// account just like sessions, so logging out everywhere has to include them.
+func revokeUserAccessTokens(tx *gorm.DB, userID string) error {
+	return tx.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// generateAccessToken signs a short-lived access token bound to a session
+func generateAccessToken(user models.User, sessionID string) (string, error) {
+	return tokens.Sign(jwt.MapClaims{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"sid":      sessionID,
+		"purpose":  "access",
+		"iss":      tokens.Issuer,
+		"aud":      tokens.AudienceAccess,
+		"exp":      time.Now().Add(accessTokenTTL).Unix(),
+	})
+}
+
+func setSessionCookies(c *fiber.Ctx, accessToken, refreshToken string, refreshExpiresAt time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "user_token",
+		Value:    accessToken,
+		Expires:  time.Now().Add(accessTokenTTL),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/",
+	})
+	// The refresh token is only ever needed by /api/users/refresh and /api/users/logout
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Expires:  refreshExpiresAt,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/api/users",
+	})
+}
+
+func clearSessionCookies(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "user_token",
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Hour), // Expire immediately
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/",
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/api/users",
+	})
+}
@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tagWithCount is a tag together with its number of published posts
+type tagWithCount struct {
+	models.Tag
+	BlogCount int64
+}
+
+// GetTags lists tags used by published posts, most used first
+func GetTags(c *fiber.Ctx) error {
+	var tags []tagWithCount
+	database.DB.Table("tags").
+		Select("tags.*, COUNT(blogs.id) AS blog_count").
+		Joins("JOIN blog_tags ON blog_tags.tag_id = tags.id").
+		Joins("JOIN blogs ON blogs.id = blog_tags.blog_id AND blogs.status = ? AND blogs.deleted_at IS NULL", models.BlogStatusPublished).
+		Group("tags.id").
+		Order("blog_count DESC, tags.name").
+		Scan(&tags)
+
+	response := []models.TagResponse{}
+	for _, tag := range tags {
+		response = append(response, toTagResponse(tag.Tag, &tag.BlogCount))
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// AutocompleteTags suggests existing tags whose slug starts with the normalized ?q=
+func AutocompleteTags(c *fiber.Ctx) error {
+	prefix := slug.Make(c.Query("q"))
+	if prefix == "" {
+		return c.Status(fiber.StatusOK).JSON([]models.TagResponse{})
+	}
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	// Slugs only contain [a-z0-9-], so the prefix needs no LIKE escaping
+	var tags []tagWithCount
+	database.DB.Table("tags").
+		Select("tags.*, COUNT(blogs.id) AS blog_count").
+		Joins("LEFT JOIN blog_tags ON blog_tags.tag_id = tags.id").
+		Joins("LEFT JOIN blogs ON blogs.id = blog_tags.blog_id AND blogs.status = ? AND blogs.deleted_at IS NULL", models.BlogStatusPublished).
+		Where("tags.slug LIKE ?", prefix+"%").
+		Group("tags.id").
+		Order("blog_count DESC, tags.slug").
+		Limit(limit).
+		Scan(&tags)
+
+	response := []models.TagResponse{}
+	for _, tag := range tags {
+		response = append(response, toTagResponse(tag.Tag, &tag.BlogCount))
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// GetTagBlogs lists the published posts with a tag, with the same paging and filters as GetBlogs
+func GetTagBlogs(c *fiber.Ctx) error {
+	var tag models.Tag
+	if err := database.DB.First(&tag, "slug = ?", c.Params("slug")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	}
+
+	query := database.DB.Model(&models.Blog{}).
+		Joins("JOIN blog_tags ON blog_tags.blog_id = blogs.id").
+		Where("blog_tags.tag_id = ? AND blogs.status = ?", tag.ID, models.BlogStatusPublished)
+	page, fiberErr := pageOfBlogs(c, query)
+	if fiberErr != nil {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(struct {
+		Tag models.TagResponse `json:"tag"`
+		helpers.ListPage[interface{}]
+	}{toTagResponse(tag, &page.Total), page})
+}
+
+// RenameTag changes a tag's name and slug. Renaming onto an existing tag has to be a merge.
+func RenameTag(c *fiber.Ctx) error {
+	var tag models.Tag
+	if err := database.DB.First(&tag, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	}
+
+	var input models.TagUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	name, tagSlug, err := normalizeTagName(input.Name)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var existing models.Tag
+	if err := database.DB.First(&existing, "slug = ? AND id <> ?", tagSlug, tag.ID).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A tag with this name already exists, merge the tags instead",
+			"tag":   toTagResponse(existing, nil),
+		})
+	}
+
+	tag.Name = name
+	tag.Slug = tagSlug
+	if err := database.DB.Save(&tag).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to rename tag"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(toTagResponse(tag, nil))
+}
+
+// MergeTag moves every post from one tag to another and deletes the first one
+func MergeTag(c *fiber.Ctx) error {
+	var source models.Tag
+	if err := database.DB.First(&source, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	}
+
+	var input models.TagMerge
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	var target models.Tag
+	if err := database.DB.First(&target, "slug = ?", slug.Make(input.Into)).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Target tag not found"})
+	}
+	if target.ID == source.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A tag can't be merged into itself"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Posts that already have both tags keep a single link
+		err := tx.Exec(`
+			INSERT INTO blog_tags (blog_id, tag_id, created_at)
+			SELECT blog_id, ?, created_at FROM blog_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`,
+			target.ID, source.ID,
+		).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&models.BlogTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&source).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to merge tags"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(toTagResponse(target, nil))
+}
+
+func toTagResponse(tag models.Tag, blogCount *int64) models.TagResponse {
+	return models.TagResponse{
+		ID:        tag.ID.String(),
+		Name:      tag.Name,
+		Slug:      tag.Slug,
+		BlogCount: blogCount,
+	}
+}
+
+// normalizeTagName trims and collapses whitespace and returns the name with its slug
+func normalizeTagName(name string) (string, string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if utf8.RuneCountInString(name) > models.MaxTagNameLength {
+		return "", "", fmt.Errorf("Tag %q is longer than %d characters", name, models.MaxTagNameLength)
+	}
+	tagSlug := slug.Make(name)
+	if tagSlug == "" {
+		return "", "", fmt.Errorf("Invalid tag %q", name)
+	}
+	return name, tagSlug, nil
+}
+
+// parseTagList splits a comma separated form value into tag names
+func parseTagList(value string) []string {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// validateTags normalizes tag names, dropping duplicates by slug
+func validateTags(names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	seen := map[string]bool{}
+	for _, raw := range names {
+		name, tagSlug, err := normalizeTagName(raw)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tagSlug] {
+			continue
+		}
+		seen[tagSlug] = true
+		tags = append(tags, models.Tag{Name: name, Slug: tagSlug})
+	}
+	if len(tags) > models.MaxTagsPerBlog {
+		return nil, fmt.Errorf("A post can have at most %d tags", models.MaxTagsPerBlog)
+	}
+	return tags, nil
+}
+
+// setBlogTags replaces a post's tags, creating tags that don't exist yet. Existing tags keep
+// their name; the first spelling used wins.
+func setBlogTags(tx *gorm.DB, blogID uuid.UUID, tags []models.Tag) error {
+	for i := range tags {
+		// Another post may create the same tag concurrently, so insert-or-ignore then load
+		tag := tags[i]
+		if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "slug"}}, DoNothing: true}).Create(&tag).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&tags[i], "slug = ?", tag.Slug).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("blog_id = ?", blogID).Delete(&models.BlogTag{}).Error; err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := tx.Create(&models.BlogTag{BlogID: blogID, TagID: tag.ID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadBlogTags fills in the tags of every post with a single query
+func loadBlogTags(blogs []models.Blog) {
+	if len(blogs) == 0 {
+		return
+	}
+	ids := make([]uuid.UUID, len(blogs))
+	for i, blog := range blogs {
+		ids[i] = blog.ID
+	}
+
+	var rows []struct {
+		models.Tag
+		BlogID uuid.UUID
+	}
+	database.DB.Table("tags").
+		Select("tags.*, blog_tags.blog_id").
+		Joins("JOIN blog_tags ON blog_tags.tag_id = tags.id").
+		Where("blog_tags.blog_id IN ?", ids).
+		Order("tags.name").
+		Scan(&rows)
+
+	byBlog := map[uuid.UUID][]models.Tag{}
+	for _, row := range rows {
+		byBlog[row.BlogID] = append(byBlog[row.BlogID], row.Tag)
+	}
+	for i := range blogs {
+		blogs[i].Tags = byBlog[blogs[i].ID]
+	}
+}
+
+// tagsInput reads the tags field of a create or edit form. Its absence leaves the tags alone.
+func tagsInput(c *fiber.Ctx) ([]models.Tag, bool, error) {
+	value, ok := formField(c, "tags")
+	if !ok {
+		return nil, false, nil
+	}
+	tags, err := validateTags(parseTagList(value))
+	return tags, true, err
+}
+
+// formField returns a form value and whether the field was sent at all, for both
+// urlencoded and multipart bodies
+func formField(c *fiber.Ctx, key string) (string, bool) {
+	if form, err := c.MultipartForm(); err == nil {
+		if values := form.Value[key]; len(values) > 0 {
+			return values[0], true
+		}
+		return "", false
+	}
+	if args := c.Request().PostArgs(); args.Has(key) {
+		return string(args.Peek(key)), true
+	}
+	return "", false
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/policy"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// openTestDB points database.DB at the Postgres database in TEST_DATABASE_URL and
+// migrates it. The database should be a disposable one: tests leave their rows behind.
+// Tests that need a database are skipped without it.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	testDBOnce.Do(func() {
+		database.DB, testDBErr = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if testDBErr != nil {
+			return
+		}
+		if testDBErr = database.DB.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; testDBErr != nil {
+			return
+		}
+		testDBErr = database.Migrate()
+	})
+	if testDBErr != nil {
+		t.Fatalf("test database: %v", testDBErr)
+	}
+}
+
+// useUnreachableDB points database.DB at a server that isn't there, so every query
+// fails like it would when the database goes away
+func useUnreachableDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1"), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+}
+
+// testSuffix makes names unique across test runs on the same database
+func testSuffix() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+}
+
+func createTestUser(t *testing.T, name string) models.User {
+	t.Helper()
+	username := name + testSuffix()
+	user := models.User{
+		Name:     name,
+		Surname:  "Test",
+		Username: username,
+		Email:    username + "@example.com",
+		Password: []byte("not-a-hash"),
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
+
+// grantTestRole makes sure the admin role holds the permission
+func grantTestRole(t *testing.T, role, permission string) {
+	t.Helper()
+	grant := models.RolePermission{Role: role, Permission: permission}
+	if err := database.DB.Where(grant).FirstOrCreate(&grant).Error; err != nil {
+		t.Fatalf("grant %s to %s: %v", permission, role, err)
+	}
+}
+
+// createTestBlog saves a post of the owner with its first revision
+func createTestBlog(t *testing.T, owner models.User, title, status string) models.Blog {
+	t.Helper()
+	blog := models.Blog{
+		Title:         title,
+		Content:       "Body of " + title,
+		ContentFormat: "markdown",
+		Language:      models.DefaultBlogLanguage,
+		Slug:          "post-" + testSuffix(),
+		Summary:       "Summary of " + title,
+		UserID:        owner.ID.String(),
+		Status:        status,
+		Visibility:    status == models.BlogStatusPublished,
+		Version:       1,
+	}
+	if err := database.DB.Create(&blog).Error; err != nil {
+		t.Fatalf("create blog: %v", err)
+	}
+	// Create skips false since the column defaults to true
+	if !blog.Visibility {
+		database.DB.Model(&blog).UpdateColumn("visibility", false)
+	}
+	if err := recordRevision(database.DB, blog, policy.Actor{UserID: owner.ID.String()}, nil); err != nil {
+		t.Fatalf("record revision: %v", err)
+	}
+	return blog
+}
+
+func addTestCollaborator(t *testing.T, blog models.Blog, user models.User, role string) {
+	t.Helper()
+	collaborator := models.BlogCollaborator{BlogID: blog.ID, UserID: user.ID.String(), Role: role}
+	if err := database.DB.Create(&collaborator).Error; err != nil {
+		t.Fatalf("add collaborator: %v", err)
+	}
+}
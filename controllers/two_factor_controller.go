@@ -0,0 +1,502 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/tokens"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+
+	challengePurposeUser  = "user_2fa_challenge"
+	challengePurposeAdmin = "admin_2fa_challenge"
+
+	// twoFactorChallengeCookie carries the challenge of a social login, which would otherwise
+	// end up in the redirect URL and from there in browser history, logs and Referer headers
+	twoFactorChallengeCookie = "two_factor_challenge"
+
+	// maxChallengeAttempts is how many codes one challenge accepts before the password step must be redone
+	maxChallengeAttempts = 5
+	// maxTwoFactorAttemptsPerIP caps codes tried from one IP across all challenges in the window
+	maxTwoFactorAttemptsPerIP = 30
+	twoFactorAttemptWindow    = 15 * time.Minute
+)
+
+// twoFactorSubject lets users and admins share the 2FA handlers
+type twoFactorSubject struct {
+	model     interface{} // *models.User or *models.AdminUser
+	ownerType string
+	ownerID   string
+	account   string
+	secret    string
+	enabled   bool
+	lastStep  int64
+}
+
+func userTwoFactorSubject(user *models.User) twoFactorSubject {
+	return twoFactorSubject{
+		model:     user,
+		ownerType: models.OwnerTypeUser,
+		ownerID:   user.ID.String(),
+		account:   user.Email,
+		secret:    user.TOTPSecret,
+		enabled:   user.TOTPEnabled,
+		lastStep:  user.TOTPLastStep,
+	}
+}
+
+func adminTwoFactorSubject(admin *models.AdminUser) twoFactorSubject {
+	return twoFactorSubject{
+		model:     admin,
+		ownerType: models.OwnerTypeAdmin,
+		ownerID:   admin.ID.String(),
+		account:   admin.Email,
+		secret:    admin.TOTPSecret,
+		enabled:   admin.TOTPEnabled,
+		lastStep:  admin.TOTPLastStep,
+	}
+}
+
+// User endpoints
+
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	return enrollTwoFactor(c, userTwoFactorSubject(&user))
+}
+
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	return confirmTwoFactor(c, userTwoFactorSubject(&user))
+}
+
+func DisableTwoFactor(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input models.TwoFactorDisable
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := helpers.VerifyPassword(input.Password, string(user.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Password is incorrect"})
+	}
+
+	return disableTwoFactor(c, userTwoFactorSubject(&user), input)
+}
+
+// LoginTwoFactor completes a login that returned a challenge token
+func LoginTwoFactor(c *fiber.Ctx) error {
+	var input models.TwoFactorLogin
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	challengeToken := input.ChallengeToken
+	if challengeToken == "" {
+		challengeToken = c.Cookies(twoFactorChallengeCookie)
+	}
+	userID, challengeID, err := parseTwoFactorChallenge(challengeToken, challengePurposeUser)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired challenge"})
+	}
+	if err := recordTwoFactorAttempt(c, challengeID); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired challenge"})
+	}
+
+	// Wrong codes count against the same limits as wrong passwords
+	accountKey := helpers.AccountThrottleKey(models.OwnerTypeUser, user.ID.String())
+	ipKey := helpers.IPThrottleKey(c.IP())
+	if wait, blocked := helpers.LoginBlocked(accountKey, ipKey); blocked {
+		return tooManyLoginAttempts(c, wait)
+	}
+
+	if !verifySecondFactor(userTwoFactorSubject(&user), input.Code, input.RecoveryCode) {
+		helpers.RecordLoginFailure(accountKey, ipKey)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid two-factor code"})
+	}
+
+	if err := startSession(c, user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	clearTwoFactorAttempts(challengeID)
+	clearTwoFactorChallengeCookie(c)
+	helpers.ResetLoginThrottle(accountKey)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Login successful"})
+}
+
+// Admin endpoints
+
+func EnrollAdminTwoFactor(c *fiber.Ctx) error {
+	admin := c.Locals("admin").(models.AdminUser)
+	return enrollTwoFactor(c, adminTwoFactorSubject(&admin))
+}
+
+func ConfirmAdminTwoFactor(c *fiber.Ctx) error {
+	admin := c.Locals("admin").(models.AdminUser)
+	return confirmTwoFactor(c, adminTwoFactorSubject(&admin))
+}
+
+func DisableAdminTwoFactor(c *fiber.Ctx) error {
+	admin := c.Locals("admin").(models.AdminUser)
+
+	if helpers.GetSetting(models.SettingAdminRequire2FA, "false") == "true" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden - Two-factor authentication is mandatory for admins"})
+	}
+
+	var input models.TwoFactorDisable
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := helpers.VerifyPassword(input.Password, admin.Password); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Password is incorrect"})
+	}
+
+	return disableTwoFactor(c, adminTwoFactorSubject(&admin), input)
+}
+
+// AdminLoginTwoFactor completes an admin login that returned a challenge token
+func AdminLoginTwoFactor(c *fiber.Ctx) error {
+	var input models.TwoFactorLogin
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	adminID, challengeID, err := parseTwoFactorChallenge(input.ChallengeToken, challengePurposeAdmin)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired challenge"})
+	}
+	if err := recordTwoFactorAttempt(c, challengeID); err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	}
+
+	var admin models.AdminUser
+	if err := database.DB.First(&admin, "id = ?", adminID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired challenge"})
+	}
+
+	accountKey := helpers.AccountThrottleKey(models.OwnerTypeAdmin, admin.ID.String())
+	ipKey := helpers.IPThrottleKey(c.IP())
+	if wait, blocked := helpers.LoginBlocked(accountKey, ipKey); blocked {
+		return tooManyLoginAttempts(c, wait)
+	}
+
+	if !verifySecondFactor(adminTwoFactorSubject(&admin), input.Code, input.RecoveryCode) {
+		helpers.RecordLoginFailure(accountKey, ipKey)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid two-factor code"})
+	}
+
+	if err := setAdminToken(c, admin); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	clearTwoFactorAttempts(challengeID)
+	helpers.ResetLoginThrottle(accountKey)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Login successful",
+		"admin":   toAdminUserResponse(admin),
+	})
+}
+
+func GetAdminTwoFactorSetting(c *fiber.Ctx) error {
+	required := helpers.GetSetting(models.SettingAdminRequire2FA, "false") == "true"
+	return c.Status(fiber.StatusOK).JSON(models.AdminTwoFactorSetting{Required: required})
+}
+
+// UpdateAdminTwoFactorSetting makes 2FA mandatory (or optional) for every admin
+func UpdateAdminTwoFactorSetting(c *fiber.Ctx) error {
+	var input models.AdminTwoFactorSetting
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	value := "false"
+	if input.Required {
+		value = "true"
+	}
+	if err := helpers.SetSetting(models.SettingAdminRequire2FA, value); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update setting"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(input)
+}
+
+// Shared 2FA flow
+
+// enrollTwoFactor stores a new pending secret; 2FA stays off until confirmTwoFactor
+func enrollTwoFactor(c *fiber.Ctx, subject twoFactorSubject) error {
+	if subject.enabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+
+	secret := helpers.GenerateTOTPSecret()
+	if err := database.DB.Model(subject.model).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start enrollment"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.TwoFactorEnrollment{
+		Secret:     secret,
+		OtpauthURI: helpers.TOTPURI(totpIssuer(), subject.account, secret),
+	})
+}
+
+// confirmTwoFactor enables 2FA once the first code checks out and returns fresh recovery codes
+func confirmTwoFactor(c *fiber.Ctx, subject twoFactorSubject) error {
+	if subject.enabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+	if subject.secret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Start enrollment first"})
+	}
+
+	var input models.TwoFactorCode
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	step, ok := helpers.ValidateTOTP(subject.secret, input.Code, time.Now())
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid two-factor code"})
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(subject.model).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, subject.ownerType, subject.ownerID)
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to enable two-factor authentication"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func disableTwoFactor(c *fiber.Ctx, subject twoFactorSubject, input models.TwoFactorDisable) error {
+	if !subject.enabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+	if !verifySecondFactor(subject, input.Code, input.RecoveryCode) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid two-factor code"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(subject.model).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("owner_type = ? AND owner_id = ?", subject.ownerType, subject.ownerID).
+			Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to disable two-factor authentication"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+// verifySecondFactor accepts either a TOTP code that wasn't used before or an unused recovery code
+func verifySecondFactor(subject twoFactorSubject, code, recoveryCode string) bool {
+	if !subject.enabled {
+		return false
+	}
+
+	if code != "" {
+		step, ok := helpers.ValidateTOTP(subject.secret, code, time.Now())
+		if !ok {
+			return false
+		}
+		// Only move forward so a code can't be replayed within its window
+		result := database.DB.Model(subject.model).
+			Where("totp_last_step < ?", step).
+			UpdateColumn("totp_last_step", step)
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	if recoveryCode != "" {
+		return useRecoveryCode(subject.ownerType, subject.ownerID, recoveryCode)
+	}
+
+	return false
+}
+
+// replaceRecoveryCodes deletes old recovery codes and returns a new plaintext set
+func replaceRecoveryCodes(tx *gorm.DB, ownerType, ownerID string) ([]string, error) {
+	if err := tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code := helpers.GenerateRecoveryCode()
+		hash, err := helpers.HashPassword(normalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{OwnerType: ownerType, OwnerID: ownerID, CodeHash: hash})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode marks a matching unused recovery code as used
+func useRecoveryCode(ownerType, ownerID, code string) bool {
+	code = normalizeRecoveryCode(code)
+
+	var rows []models.RecoveryCode
+	database.DB.Where("owner_type = ? AND owner_id = ? AND used_at IS NULL", ownerType, ownerID).Find(&rows)
+
+	for _, row := range rows {
+		if helpers.VerifyPassword(code, row.CodeHash) != nil {
+			continue
+		}
+		result := database.DB.Model(&row).Where("used_at IS NULL").Update("used_at", time.Now())
+		return result.Error == nil && result.RowsAffected == 1
+	}
+	return false
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+// generateTwoFactorChallenge signs a short-lived token proving the password step succeeded.
+// The jti identifies the challenge so its attempts can be counted.
+func generateTwoFactorChallenge(subjectID, purpose string) (string, error) {
+	return tokens.Sign(jwt.MapClaims{
+		"user_id": subjectID,
+		"purpose": purpose,
+		"jti":     uuid.NewString(),
+		"iss":     tokens.Issuer,
+		"aud":     tokens.AudienceTwoFactor,
+		"exp":     time.Now().Add(twoFactorChallengeTTL).Unix(),
+	})
+}
+
+// setTwoFactorChallengeCookie hands a user challenge to the browser in an httpOnly cookie
+// that is only sent to the 2FA login endpoint and expires with the challenge
+func setTwoFactorChallengeCookie(c *fiber.Ctx, challenge string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     twoFactorChallengeCookie,
+		Value:    challenge,
+		Expires:  time.Now().Add(twoFactorChallengeTTL),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/api/users/login/2fa",
+	})
+}
+
+func clearTwoFactorChallengeCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     twoFactorChallengeCookie,
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: helpers.CookieSameSite(),
+		Path:     "/api/users/login/2fa",
+	})
+}
+
+// parseTwoFactorChallenge returns the subject and the challenge ID of a valid challenge token
+func parseTwoFactorChallenge(tokenString, purpose string) (string, string, error) {
+	claims := jwt.MapClaims{}
+	parsedToken, err := tokens.Parse(tokenString, claims, jwt.WithAudience(tokens.AudienceTwoFactor), jwt.WithIssuer(tokens.Issuer))
+	if err != nil || !parsedToken.Valid || claims["purpose"] != purpose {
+		return "", "", errors.New("invalid challenge token")
+	}
+
+	subjectID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", "", errors.New("invalid challenge token")
+	}
+	challengeID, ok := claims["jti"].(string)
+	if _, err := uuid.Parse(challengeID); !ok || err != nil {
+		return "", "", errors.New("invalid challenge token")
+	}
+	return subjectID, challengeID, nil
+}
+
+// recordTwoFactorAttempt counts an attempt before the code is checked, so concurrent
+// guesses can't slip past the limits. It fails once the challenge is used up or the
+// client IP has tried too many codes.
+func recordTwoFactorAttempt(c *fiber.Ctx, challengeID string) *fiber.Error {
+	since := time.Now().Add(-twoFactorAttemptWindow)
+	database.DB.Where("created_at < ?", since).Delete(&models.TwoFactorAttempt{})
+
+	if err := database.DB.Create(&models.TwoFactorAttempt{ChallengeID: challengeID, IP: c.IP()}).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify two-factor code")
+	}
+
+	var perChallenge, perIP int64
+	if err := database.DB.Model(&models.TwoFactorAttempt{}).
+		Where("challenge_id = ?", challengeID).Count(&perChallenge).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify two-factor code")
+	}
+	if perChallenge > maxChallengeAttempts {
+		return fiber.NewError(fiber.StatusUnauthorized, "Too many attempts for this challenge, log in again")
+	}
+
+	if err := database.DB.Model(&models.TwoFactorAttempt{}).
+		Where("ip = ? AND created_at >= ?", c.IP(), since).Count(&perIP).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify two-factor code")
+	}
+	if perIP > maxTwoFactorAttemptsPerIP {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(twoFactorAttemptWindow.Seconds())))
+		return fiber.NewError(fiber.StatusTooManyRequests, "Too many two-factor attempts, try again later")
+	}
+	return nil
+}
+
+// clearTwoFactorAttempts forgets a challenge's attempts once it succeeded, so
+// successful logins don't count against the IP
+func clearTwoFactorAttempts(challengeID string) {
+	database.DB.Where("challenge_id = ?", challengeID).Delete(&models.TwoFactorAttempt{})
+}
+
+func totpIssuer() string {
+	if issuer := os.Getenv("APP_NAME"); issuer != "" {
+		return issuer
+	}
+	return "Blog"
+}
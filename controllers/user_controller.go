@@ -1,15 +1,19 @@
 package controllers
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/nurullahgd/main-blog-backend/database"
 	"github.com/nurullahgd/main-blog-backend/helpers"
 	"github.com/nurullahgd/main-blog-backend/models"
 	"github.com/nurullahgd/main-blog-backend/utils"
+	"gorm.io/gorm"
 )
 
 func Register(c *fiber.Ctx) error {
@@ -18,6 +22,18 @@ func Register(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	input.Email = strings.TrimSpace(input.Email)
+	input.Username = strings.TrimSpace(input.Username)
+	if input.Name == "" || input.Surname == "" || input.Username == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name, surname and username are required"})
+	}
+	if !helpers.ValidateEmail(input.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid email address"})
+	}
+	if len(input.Password) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Password must be at least %d characters", minPasswordLength)})
+	}
+
 	// Check if email already exists
 	var existingUser models.User
 	if err := database.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
@@ -48,23 +64,14 @@ func Register(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create user"})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-	})
+	// The account is usable right away; verification is only enforced where the policy requires it
+	if err := sendVerificationEmail(user); err != nil {
+		log.Println("Failed to send verification email:", err)
+	}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	if err != nil {
+	if err := startSession(c, user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
 	}
-	c.Cookie(&fiber.Cookie{
-		Name:     "user_token",
-		Value:    tokenString,
-		Expires:  time.Now().Add(24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   true,
-	})
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "User created successfully"})
 }
@@ -80,56 +87,74 @@ func Login(c *fiber.Ctx) error {
 	}
 
 	var user models.User
-	if err := database.DB.Where("email = ? OR username = ?", input.Input, input.Input).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+	found := database.DB.Where("email = ? OR username = ?", input.Input, input.Input).First(&user).Error == nil
+
+	// Unknown logins are throttled under their own key, so they behave exactly like real accounts
+	accountKey := helpers.AccountThrottleKey(models.OwnerTypeUser, input.Input)
+	if found {
+		accountKey = helpers.AccountThrottleKey(models.OwnerTypeUser, user.ID.String())
+	}
+	ipKey := helpers.IPThrottleKey(c.IP())
+	if wait, blocked := helpers.LoginBlocked(accountKey, ipKey); blocked {
+		return tooManyLoginAttempts(c, wait)
 	}
 
-	if err := helpers.VerifyPassword(input.Password, string(user.Password)); err != nil {
+	if !helpers.VerifyPasswordConstantTime(input.Password, string(user.Password), found) {
+		helpers.RecordLoginFailure(accountKey, ipKey)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-	})
+	// With 2FA on, no session is issued until /login/2fa verifies the second factor
+	if user.TOTPEnabled {
+		challenge, err := generateTwoFactorChallenge(user.ID.String(), challengePurposeUser)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+	}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	if err != nil {
+	if err := startSession(c, user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
 	}
-	c.Cookie(&fiber.Cookie{
-		Name:     "user_token",
-		Value:    tokenString,
-		Expires:  time.Now().Add(24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   true,
-	})
+	helpers.ResetLoginThrottle(accountKey)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Login successful"})
 }
 
+// userListOptions are the sort keys accepted by the user list
+var userListOptions = helpers.ListOptions{
+	Table: "users",
+	Sorts: map[string]string{
+		"created_at": "users.created_at",
+		"username":   "users.username",
+		"name":       "users.name",
+		"blog_count": "users.blog_count",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetUsers lists users a page at a time, optionally filtered by ?from=/?to= (registration date)
 func GetUsers(c *fiber.Ctx) error {
-	var users []models.User
-	database.DB.Find(&users)
-
-	// Convert to response format
-	var response []models.UserResponse
-	for _, user := range users {
-		response = append(response, models.UserResponse{
-			ID:           user.ID.String(),
-			Name:         user.Name,
-			Surname:      user.Surname,
-			Username:     user.Username,
-			Email:        user.Email,
-			ProfileImage: user.ProfileImage,
-			BlogCount:    user.BlogCount,
-			CreatedAt:    user.CreatedAt,
-			UpdatedAt:    user.UpdatedAt,
-		})
+	params, err := helpers.ParseListParams(c, userListOptions)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	query, err := filterCreatedAt(c, database.DB.Model(&models.User{}), "users")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	page, err := helpers.Paginate(query, params, func(user models.User) (time.Time, string) {
+		return user.CreatedAt, user.ID.String()
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load users"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response)
+	return c.Status(fiber.StatusOK).JSON(helpers.MapListPage(page, toUserResponse))
 }
 
 func GetUser(c *fiber.Ctx) error {
@@ -138,37 +163,13 @@ func GetUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	response := models.UserResponse{
-		ID:           user.ID.String(),
-		Name:         user.Name,
-		Surname:      user.Surname,
-		Username:     user.Username,
-		Email:        user.Email,
-		ProfileImage: user.ProfileImage,
-		BlogCount:    user.BlogCount,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-	}
-
-	return c.Status(fiber.StatusOK).JSON(response)
+	c.Set(fiber.HeaderETag, versionETag(user.Version))
+	return c.Status(fiber.StatusOK).JSON(toUserResponse(user))
 }
 
 func EditUser(c *fiber.Ctx) error {
-	// Kullanıcı ID'sini cookie'den al
-	userToken := c.Cookies("user_token")
-	if userToken == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Unauthorized",
-		})
-	}
-
-	// Token'ı doğrula ve kullanıcı ID'sini al
-	userID, err := helpers.GetUserIDFromToken(userToken)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid token",
-		})
-	}
+	// Kullanıcı ID'sini context'ten al (AuthMiddleware)
+	userID := c.Locals("userID").(string)
 
 	// Kullanıcıyı bul
 	var user models.User
@@ -178,6 +179,19 @@ func EditUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reject edits that aren't based on the current copy of the profile. The version
+	// can also come as a form field.
+	version := c.FormValue("version")
+	if c.Get(fiber.HeaderIfMatch) == "" && version == "" {
+		return preconditionRequired(c, user.Version)
+	}
+	if !ifMatchSatisfied(c, user.Version) {
+		return preconditionFailed(c, user.Version)
+	}
+	if version != "" && version != strconv.Itoa(user.Version) {
+		return preconditionFailed(c, user.Version)
+	}
+
 	// Form verilerini al
 	name := c.FormValue("name")
 	surname := c.FormValue("surname")
@@ -191,35 +205,30 @@ func EditUser(c *fiber.Ctx) error {
 	}
 
 	// Kullanıcıyı güncelle
-	if err := database.DB.Save(&user).Error; err != nil {
+	if err := saveVersioned(database.DB, &user, &user.Version, "name", "surname"); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			return versionConflict(c, &models.User{}, user.ID)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update user",
 		})
 	}
-	var userResponse models.UserResponse
-	userResponse.ID = user.ID.String()
-	userResponse.Name = user.Name
-	userResponse.Surname = user.Surname
-	userResponse.Username = user.Username
-	userResponse.Email = user.Email
-	userResponse.ProfileImage = user.ProfileImage
-	userResponse.BlogCount = user.BlogCount
 
-	return c.JSON(userResponse)
+	c.Set(fiber.HeaderETag, versionETag(user.Version))
+	return c.JSON(toUserResponse(user))
 }
 
 func UploadProfileImage(c *fiber.Ctx) error {
-	userToken := c.Cookies("user_token")
-	userID, err := helpers.GetUserIDFromToken(userToken)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
+	userID := c.Locals("userID").(string)
 
 	// Get user
 	var user models.User
 	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
+	if handled, err := checkIfMatch(c, user.Version); handled {
+		return err
+	}
 
 	// Get file from form
 	file, err := c.FormFile("image")
@@ -238,34 +247,93 @@ func UploadProfileImage(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Delete old image if exists
-	if user.ProfileImage != "" {
-		publicID := utils.GetPublicIDFromURL(user.ProfileImage)
-		utils.DeleteFromCloudinary(publicID)
-	}
-
 	// Update user
+	oldImage := user.ProfileImage
 	user.ProfileImage = imageURL
-	if err := database.DB.Save(&user).Error; err != nil {
+	if err := saveVersioned(database.DB, &user, &user.Version, "profile_image"); err != nil {
+		// The new upload is unused now
+		utils.DeleteFromCloudinary(utils.GetPublicIDFromURL(imageURL))
+		if errors.Is(err, errVersionConflict) {
+			return versionConflict(c, &models.User{}, user.ID)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save user profile"})
 	}
+	c.Set(fiber.HeaderETag, versionETag(user.Version))
+
+	// Delete old image only once the new one is saved
+	if oldImage != "" {
+		publicID := utils.GetPublicIDFromURL(oldImage)
+		utils.DeleteFromCloudinary(publicID)
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Profile image updated successfully"})
 }
 
 func Logout(c *fiber.Ctx) error {
-	// Clear the cookie
-	c.Cookie(&fiber.Cookie{
-		Name:     "user_token",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour), // Expire immediately
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: "None",
-		Path:     "/",
-	})
+	// Revoke the session server-side so its refresh token can't be used again
+	if refreshToken := c.Cookies("refresh_token"); refreshToken != "" {
+		revokeSessionByRefreshToken(refreshToken)
+	}
+
+	// Clear the cookies
+	clearSessionCookies(c)
 
 	return c.JSON(fiber.Map{
 		"message": "Logout successful",
 	})
 }
+
+func toUserResponse(user models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:            user.ID.String(),
+		Name:          user.Name,
+		Surname:       user.Surname,
+		Username:      user.Username,
+		Email:         user.Email,
+		ProfileImage:  user.ProfileImage,
+		BlogCount:     user.BlogCount,
+		EmailVerified: user.EmailVerified,
+		Version:       user.Version,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+func toAuthorResponse(user models.User) models.AuthorResponse {
+	return models.AuthorResponse{
+		ID:           user.ID.String(),
+		Username:     user.Username,
+		Name:         user.Name,
+		Surname:      user.Surname,
+		ProfileImage: user.ProfileImage,
+	}
+}
+
+// filterCreatedAt applies ?from= and ?to= to the created_at column of table
+func filterCreatedAt(c *fiber.Ctx, query *gorm.DB, table string) (*gorm.DB, error) {
+	from, err := helpers.ParseDateParam(c.Query("from"), false)
+	if err != nil {
+		return nil, err
+	}
+	if from != nil {
+		query = query.Where(table+".created_at >= ?", *from)
+	}
+	to, err := helpers.ParseDateParam(c.Query("to"), true)
+	if err != nil {
+		return nil, err
+	}
+	if to != nil {
+		query = query.Where(table+".created_at <= ?", *to)
+	}
+	return query, nil
+}
+
+// tooManyLoginAttempts answers a throttled login with 429 and a Retry-After header
+func tooManyLoginAttempts(c *fiber.Ctx, wait time.Duration) error {
+	retryAfter := int(wait.Seconds()) + 1
+	c.Set(fiber.HeaderRetryAfter, fmt.Sprint(retryAfter))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "Too many failed login attempts, try again later",
+		"retry_after": retryAfter,
+	})
+}
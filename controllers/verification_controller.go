@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/mailer"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/tokens"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	// verificationResendInterval is the minimum time between two verification emails
+	verificationResendInterval = time.Minute
+)
+
+// VerifyEmail marks the account as verified using the signed link from the verification email
+func VerifyEmail(c *fiber.Ctx) error {
+	tokenString := c.Query("token")
+	if tokenString == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Token is required"})
+	}
+
+	claims := jwt.MapClaims{}
+	parsedToken, err := tokens.Parse(tokenString, claims, jwt.WithAudience(tokens.AudienceEmailVerification), jwt.WithIssuer(tokens.Issuer))
+	if err != nil || !parsedToken.Valid || claims["purpose"] != "email_verification" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired verification link"})
+	}
+
+	userID, _ := claims["user_id"].(string)
+	email, _ := claims["email"].(string)
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired verification link"})
+	}
+
+	// A link sent to an old address must not verify a changed one
+	if user.Email != email {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired verification link"})
+	}
+
+	if !user.EmailVerified {
+		now := time.Now()
+		if err := database.DB.Model(&user).Updates(map[string]interface{}{
+			"email_verified": true,
+			"verified_at":    now,
+		}).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify email"})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmail sends a new verification link, at most once per verificationResendInterval
+func ResendVerificationEmail(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	if user.EmailVerified {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email is already verified"})
+	}
+
+	if user.VerificationSentAt != nil {
+		if wait := verificationResendInterval - time.Since(*user.VerificationSentAt); wait > 0 {
+			retryAfter := int(wait.Seconds()) + 1
+			c.Set(fiber.HeaderRetryAfter, fmt.Sprint(retryAfter))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error":       "Please wait before requesting another verification email",
+				"retry_after": retryAfter,
+			})
+		}
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to send verification email"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Verification email sent"})
+}
+
+// sendVerificationEmail records the send time and mails a signed verification link in the background
+func sendVerificationEmail(user models.User) error {
+	tokenString, err := tokens.Sign(jwt.MapClaims{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"purpose": "email_verification",
+		"iss":     tokens.Issuer,
+		"aud":     tokens.AudienceEmailVerification,
+		"exp":     time.Now().Add(emailVerificationTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := database.DB.Model(&user).UpdateColumn("verification_sent_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %d hours.\n\n%s/verify-email?token=%s\n",
+			user.Name, int(emailVerificationTTL.Hours()), helpers.FrontendURL(), url.QueryEscape(tokenString)),
+	}
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			log.Println("Failed to send verification email:", err)
+		}
+	}()
+
+	return nil
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/gosimple/slug"
 	"github.com/joho/godotenv"
+	"github.com/nurullahgd/main-blog-backend/content"
 	"github.com/nurullahgd/main-blog-backend/models"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -32,9 +36,266 @@ func InitDB() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	// AutoMigrate the schema
-	err = DB.AutoMigrate(&models.User{}, &models.Blog{}, &models.AdminUser{})
+	if err := Migrate(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Migrate brings the schema of DB up to date and backfills the data older versions left behind
+func Migrate() error {
+	// Checked before AutoMigrate adds the column, which is when the existing users are backfilled
+	verificationAdded := !DB.Migrator().HasColumn(&models.User{}, "EmailVerified")
+
+	err := DB.AutoMigrate(
+		&models.User{},
+		&models.Blog{},
+		&models.AdminUser{},
+		&models.RolePermission{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+		&models.RecoveryCode{},
+		&models.TwoFactorAttempt{},
+		&models.Setting{},
+		&models.UserIdentity{},
+		&models.PersonalAccessToken{},
+		&models.LoginThrottle{},
+		&models.BlogCollaborator{},
+		&models.BlogRevision{},
+		&models.Tag{},
+		&models.BlogTag{},
+		&models.Category{},
+	)
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if verificationAdded {
+		if err := markUsersVerified(); err != nil {
+			return fmt.Errorf("failed to backfill email verification: %w", err)
+		}
+	}
+
+	if err := hashAdminPasswords(); err != nil {
+		return fmt.Errorf("failed to hash admin passwords: %w", err)
+	}
+
+	if err := seedRolePermissions(); err != nil {
+		return fmt.Errorf("failed to seed role permissions: %w", err)
+	}
+
+	if err := migrateCategories(); err != nil {
+		return fmt.Errorf("failed to migrate blog categories: %w", err)
 	}
+
+	if err := migrateSearch(); err != nil {
+		return fmt.Errorf("failed to set up blog search: %w", err)
+	}
+
+	if err := backfillBlogStatus(); err != nil {
+		return fmt.Errorf("failed to backfill blog status: %w", err)
+	}
+
+	if err := backfillBlogRevisions(); err != nil {
+		return fmt.Errorf("failed to backfill blog revisions: %w", err)
+	}
+
+	if err := backfillRenderedContent(); err != nil {
+		return fmt.Errorf("failed to render blog content: %w", err)
+	}
+	return nil
+}
+
+// migrateCategories turns the free-text category column of older databases into
+// category entities, links every post to its category and drops the column
+func migrateCategories() error {
+	if !DB.Migrator().HasColumn("blogs", "category") {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Soft deleted posts are converted too, so restoring them keeps their category
+		var values []string
+		if err := tx.Table("blogs").Distinct("category").Where("category <> ''").Pluck("category", &values).Error; err != nil {
+			return err
+		}
+
+		for _, value := range values {
+			name := strings.Join(strings.Fields(value), " ")
+			categorySlug := slug.Make(name)
+			if categorySlug == "" {
+				continue
+			}
+
+			// Spellings that normalize to the same slug share a category
+			category := models.Category{Slug: categorySlug}
+			if err := tx.Where(models.Category{Slug: categorySlug}).Attrs(models.Category{Name: name}).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+			if err := tx.Table("blogs").Where("category = ?", value).Update("category_id", category.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropColumn("blogs", "category")
+	})
+}
+
+// migrateSearch adds the full-text search column of blogs, with a GIN index per language
+// and the trigger keeping it current: title weighs most, then the summary, then the plain
+// text content. Each post is stemmed with its own language.
+func migrateSearch() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`ALTER TABLE blogs ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+			// Searches match each language separately, so one index over every post isn't used
+			`DROP INDEX IF EXISTS idx_blogs_search_vector`,
+			`CREATE OR REPLACE FUNCTION blog_search_vector(language text, title text, summary text, body text) RETURNS tsvector AS $$
+				SELECT setweight(to_tsvector(language::regconfig, COALESCE(title, '')), 'A') ||
+					setweight(to_tsvector(language::regconfig, COALESCE(summary, '')), 'B') ||
+					setweight(to_tsvector(language::regconfig, COALESCE(body, '')), 'C')
+			$$ LANGUAGE sql IMMUTABLE`,
+			`CREATE OR REPLACE FUNCTION blogs_search_vector_update() RETURNS trigger AS $$
+			BEGIN
+				NEW.search_vector := blog_search_vector(NEW.language, NEW.title, NEW.summary, NEW.content_text);
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS blogs_search_vector_update ON blogs`,
+			`CREATE TRIGGER blogs_search_vector_update
+				BEFORE INSERT OR UPDATE OF title, summary, content_text, language ON blogs
+				FOR EACH ROW EXECUTE FUNCTION blogs_search_vector_update()`,
+			// Posts from before the trigger existed
+			`UPDATE blogs SET search_vector = blog_search_vector(language, title, summary, content_text) WHERE search_vector IS NULL`,
+		}
+		// Languages come from a fixed list, so they can be spelled into the statements
+		for _, language := range models.SearchLanguages {
+			statements = append(statements, fmt.Sprintf(
+				`CREATE INDEX IF NOT EXISTS idx_blogs_search_vector_%[1]s ON blogs USING GIN (search_vector) WHERE language = '%[1]s'`,
+				language,
+			))
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillBlogStatus gives posts created before the status column existed a status
+// matching their old visibility flag. The column has no default, so those rows are NULL.
+func backfillBlogStatus() error {
+	return DB.Exec(`
+		UPDATE blogs
+		SET status = CASE WHEN visibility THEN ? ELSE ? END,
+			published_at = CASE WHEN visibility THEN created_at END
+		WHERE status IS NULL OR status = ''`,
+		models.BlogStatusPublished, models.BlogStatusDraft,
+	).Error
+}
+
+// markUsersVerified treats the users that predate email verification as verified, so
+// turning on REQUIRE_EMAIL_VERIFICATION doesn't lock them out of writing
+func markUsersVerified() error {
+	return DB.Unscoped().Model(&models.User{}).Where("email_verified = ?", false).Update("email_verified", true).Error
+}
+
+// hashAdminPasswords bcrypt-hashes admin passwords stored in plaintext before admin
+// login started comparing hashes, so existing admins can still sign in
+func hashAdminPasswords() error {
+	var admins []models.AdminUser
+	if err := DB.Unscoped().Select("id", "password").Find(&admins).Error; err != nil {
+		return err
+	}
+
+	for _, admin := range admins {
+		if _, err := bcrypt.Cost([]byte(admin.Password)); err == nil {
+			continue
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
+		if err != nil {
+			// bcrypt rejects passwords over 72 bytes; that admin has to be reset by hand
+			log.Printf("Could not hash password of admin %s: %v", admin.ID, err)
+			continue
+		}
+		if err := DB.Unscoped().Model(&admin).UpdateColumn("password", string(hashed)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedRolePermissions inserts the default permission set for roles that have none yet,
+// so edits made by a super_admin are never overwritten on restart
+func seedRolePermissions() error {
+	for role, permissions := range models.DefaultRolePermissions {
+		var count int64
+		if err := DB.Model(&models.RolePermission{}).Where("role = ?", role).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		rows := make([]models.RolePermission, 0, len(permissions))
+		for _, permission := range permissions {
+			rows = append(rows, models.RolePermission{Role: role, Permission: permission})
+		}
+		if err := DB.Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillBlogRevisions records the current content of posts that predate revision
+// history as their first revision, so the first edit doesn't lose the original
+func backfillBlogRevisions() error {
+	return DB.Exec(`
+		INSERT INTO blog_revisions (blog_id, number, title, content, content_format, summary, category, author_id, author_type, created_at)
+		SELECT b.id, 1, b.title, b.content, b.content_format, b.summary, COALESCE(c.slug, ''), b.user_id, ?, b.updated_at
+		FROM blogs b
+		LEFT JOIN categories c ON c.id = b.category_id
+		WHERE NOT EXISTS (SELECT 1 FROM blog_revisions r WHERE r.blog_id = b.id)`,
+		models.OwnerTypeUser,
+	).Error
+}
+
+// backfillRenderedContent renders posts that predate the cached HTML, plain text or reading stats
+func backfillRenderedContent() error {
+	_, err := RenderBlogs(DB.Unscoped().Where("content_html IS NULL OR content_text IS NULL OR table_of_contents IS NULL"))
+	return err
+}
+
+// RenderBlogs re-renders the posts matched by query and stores their HTML, plain text,
+// reading stats and table of contents. It returns how many posts were updated.
+func RenderBlogs(query *gorm.DB) (int, error) {
+	var blogs []models.Blog
+	updated := 0
+	err := query.
+		Select("id", "content", "content_format").
+		FindInBatches(&blogs, 100, func(tx *gorm.DB, batch int) error {
+			for _, blog := range blogs {
+				doc, err := content.Process(blog.ContentFormat, blog.Content)
+				if err != nil {
+					return fmt.Errorf("blog %s: %w", blog.ID, err)
+				}
+				// UpdateColumns leaves updated_at and version alone; the post itself didn't change
+				err = DB.Unscoped().Model(&blog).UpdateColumns(map[string]interface{}{
+					"content_html":      doc.HTML,
+					"content_text":      doc.Text,
+					"word_count":        doc.WordCount,
+					"reading_time":      doc.ReadingTime,
+					"table_of_contents": models.TableOfContents(doc.Headings),
+				}).Error
+				if err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		}).Error
+	return updated, err
 }
@@ -0,0 +1,26 @@
+package helpers
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	// CSRFCookieName is the readable cookie carrying the double-submit CSRF token
+	CSRFCookieName = "csrf_token"
+	// CSRFHeaderName is the header clients echo the CSRF token back in
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CookieSameSite returns the SameSite mode used by every auth cookie, from
+// COOKIE_SAMESITE (Lax, Strict or None). Defaults to Lax.
+func CookieSameSite() string {
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "strict":
+		return "Strict"
+	case "none":
+		return "None"
+	default:
+		return "Lax"
+	}
+}
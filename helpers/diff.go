@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Diff operation types
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// Bounds on the work done by the diff. Texts that differ by more edits, or whose changed
+// middle has more tokens, are reported as a full replacement instead. The search keeps
+// O(edits²) state and takes O(tokens × edits) steps.
+const (
+	maxDiffEdits  = 500
+	maxDiffTokens = 50000
+)
+
+var wordTokenPattern = regexp.MustCompile(`\s+|[^\s]+`)
+
+// DiffOp is a run of text that is unchanged, inserted or deleted
+type DiffOp struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// DiffLines diffs two texts line by line
+func DiffLines(a, b string) []DiffOp {
+	return diffTokens(strings.SplitAfter(a, "\n"), strings.SplitAfter(b, "\n"))
+}
+
+// DiffWords diffs two texts word by word. Whitespace is kept, so joining the
+// equal and insert runs gives back b exactly.
+func DiffWords(a, b string) []DiffOp {
+	return diffTokens(wordTokenPattern.FindAllString(a, -1), wordTokenPattern.FindAllString(b, -1))
+}
+
+// diffTokens computes a shortest edit script with Myers' algorithm
+func diffTokens(a, b []string) []DiffOp {
+	// Common prefix and suffix never take part in the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffOp
+	ops = appendDiffOp(ops, DiffEqual, a[:prefix]...)
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	ops = appendDiffOp(ops, DiffEqual, a[len(a)-suffix:]...)
+	return ops
+}
+
+func myers(a, b []string) []DiffOp {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 || n+m > maxDiffTokens {
+		return replaceAll(a, b)
+	}
+
+	// v[k] is the furthest x reached on diagonal k; trace keeps v for each edit count d
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= n+m && !found; d++ {
+		if d > maxDiffEdits {
+			return replaceAll(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edits
+	var reversed []DiffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int { return snapshot[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffOp{Type: DiffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, DiffOp{Type: DiffInsert, Text: b[y-1]})
+			} else {
+				reversed = append(reversed, DiffOp{Type: DiffDelete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	var ops []DiffOp
+	for i := len(reversed) - 1; i >= 0; i-- {
+		ops = appendDiffOp(ops, reversed[i].Type, reversed[i].Text)
+	}
+	return ops
+}
+
+func replaceAll(a, b []string) []DiffOp {
+	var ops []DiffOp
+	ops = appendDiffOp(ops, DiffDelete, a...)
+	ops = appendDiffOp(ops, DiffInsert, b...)
+	return ops
+}
+
+// appendDiffOp adds tokens to ops, merging them into the last op when the type matches
+func appendDiffOp(ops []DiffOp, opType string, tokens ...string) []DiffOp {
+	text := strings.Join(tokens, "")
+	if text == "" {
+		return ops
+	}
+	if len(ops) > 0 && ops[len(ops)-1].Type == opType {
+		ops[len(ops)-1].Text += text
+		return ops
+	}
+	return append(ops, DiffOp{Type: opType, Text: text})
+}
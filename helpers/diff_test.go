@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// joinDiff rebuilds one side of a diff from its ops
+func joinDiff(ops []DiffOp, skip string) string {
+	var text strings.Builder
+	for _, op := range ops {
+		if op.Type != skip {
+			text.WriteString(op.Text)
+		}
+	}
+	return text.String()
+}
+
+func TestDiffLines(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\n"
+	b := "one\n2\nthree\nfour\nfive\n"
+
+	ops := DiffLines(a, b)
+	want := []DiffOp{
+		{DiffEqual, "one\n"},
+		{DiffDelete, "two\n"},
+		{DiffInsert, "2\n"},
+		{DiffEqual, "three\nfour\n"},
+		{DiffInsert, "five\n"},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("ops = %v, want %v", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Fatalf("ops = %v, want %v", ops, want)
+		}
+	}
+}
+
+func TestDiffFallsBackToReplacementWhenTooLarge(t *testing.T) {
+	lines := func(prefix string, count int) string {
+		var text strings.Builder
+		for i := 0; i < count; i++ {
+			text.WriteString(prefix + strconv.Itoa(i) + "\n")
+		}
+		return text.String()
+	}
+
+	tests := map[string][2]string{
+		"too many edits":  {lines("a", maxDiffEdits), lines("b", maxDiffEdits)},
+		"too many tokens": {"x\n", lines("b", maxDiffTokens)},
+	}
+	for name, tt := range tests {
+		a, b := tt[0], tt[1]
+		ops := DiffLines("head\n"+a+"tail\n", "head\n"+b+"tail\n")
+		if joinDiff(ops, DiffInsert) != "head\n"+a+"tail\n" || joinDiff(ops, DiffDelete) != "head\n"+b+"tail\n" {
+			t.Fatalf("%s: the ops don't rebuild both texts", name)
+		}
+		if len(ops) != 4 || ops[1].Type != DiffDelete || ops[2].Type != DiffInsert {
+			t.Errorf("%s: want equal, delete, insert, equal; got %d ops", name, len(ops))
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package helpers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/mail"
 	"os"
+	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,13 +22,26 @@ func VerifyPassword(password, hashedPassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func GetUserIDFromToken(token string) (string, error) {
-	claims := jwt.MapClaims{}
-	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-	if err != nil {
-		return "", err
+// HashToken returns the hex SHA-256 of an opaque token, used to store tokens at rest
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// FrontendURL returns the base URL of the frontend used in emailed links
+func FrontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:8000"
+}
+
+// ValidateEmail reports whether email is a single bare address like "name@example.com"
+func ValidateEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
 	}
-	return claims["user_id"].(string), nil
+	// Require a dotted domain, net/mail accepts "user@localhost"
+	return strings.Contains(email[strings.LastIndex(email, "@"):], ".")
 }
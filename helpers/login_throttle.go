@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// throttlePolicy controls backoff and lockout for one kind of throttle key
+type throttlePolicy struct {
+	backoffAfter    int           // failures before exponential backoff starts
+	maxBackoff      time.Duration // cap for the backoff delay
+	lockoutAfter    int           // failures before a full lockout
+	lockoutDuration time.Duration
+}
+
+var (
+	accountThrottle = throttlePolicy{backoffAfter: 3, maxBackoff: 5 * time.Minute, lockoutAfter: 10, lockoutDuration: 30 * time.Minute}
+	// IPs are shared behind NAT, so they get more room before being blocked
+	ipThrottle = throttlePolicy{backoffAfter: 20, maxBackoff: 5 * time.Minute, lockoutAfter: 100, lockoutDuration: 30 * time.Minute}
+)
+
+// failureWindow resets the counter when the last failure is older than this
+const failureWindow = time.Hour
+
+// AccountThrottleKey returns the throttle key for a user or admin account
+func AccountThrottleKey(kind, id string) string {
+	return kind + ":" + strings.ToLower(id)
+}
+
+// IPThrottleKey returns the throttle key for a client IP
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginBlocked returns how long the caller must wait if any key is currently blocked
+func LoginBlocked(keys ...string) (time.Duration, bool) {
+	var throttles []models.LoginThrottle
+	database.DB.Where("key IN ? AND blocked_until > ?", keys, time.Now()).Find(&throttles)
+
+	var wait time.Duration
+	for _, t := range throttles {
+		if d := time.Until(*t.BlockedUntil); d > wait {
+			wait = d
+		}
+	}
+	return wait, wait > 0
+}
+
+// RecordLoginFailure bumps the failure counters for an account key and an IP key
+func RecordLoginFailure(accountKey, ipKey string) {
+	recordFailure(accountKey, accountThrottle)
+	recordFailure(ipKey, ipThrottle)
+}
+
+// ResetLoginThrottle clears the counters for the given keys
+func ResetLoginThrottle(keys ...string) error {
+	return database.DB.Where("key IN ?", keys).Delete(&models.LoginThrottle{}).Error
+}
+
+func recordFailure(key string, policy throttlePolicy) {
+	now := time.Now()
+
+	database.DB.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it so concurrent failures are all counted
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key, LastFailureAt: now}).Error; err != nil {
+			return err
+		}
+
+		var t models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "key = ?", key).Error; err != nil {
+			return err
+		}
+
+		if now.Sub(t.LastFailureAt) > failureWindow {
+			t.Failures = 0
+		}
+		t.Failures++
+		t.LastFailureAt = now
+
+		if delay := policy.delay(t.Failures); delay > 0 {
+			blockedUntil := now.Add(delay)
+			t.BlockedUntil = &blockedUntil
+		}
+
+		return tx.Save(&t).Error
+	})
+}
+
+// delay returns how long to block after the given number of consecutive failures
+func (p throttlePolicy) delay(failures int) time.Duration {
+	if failures >= p.lockoutAfter {
+		return p.lockoutDuration
+	}
+	if failures < p.backoffAfter {
+		return 0
+	}
+
+	// 1s, 2s, 4s, ... up to maxBackoff
+	delay := time.Duration(math.Pow(2, float64(failures-p.backoffAfter))) * time.Second
+	if delay > p.maxBackoff {
+		delay = p.maxBackoff
+	}
+	return delay
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// VerifyPasswordConstantTime behaves like VerifyPassword but still runs a full
+// bcrypt comparison when there is no account, so unknown users and wrong
+// passwords take the same time
+func VerifyPasswordConstantTime(password, hashedPassword string, found bool) bool {
+	if !found {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = HashPassword("dummy-password-for-timing")
+		})
+		VerifyPassword(password, dummyHash)
+		return false
+	}
+	return VerifyPassword(password, hashedPassword) == nil
+}
@@ -0,0 +1,193 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Page sizes for list endpoints
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// cursorSort is the only sort key cursors work with; other sorts page by offset
+const cursorSort = "created_at"
+
+// ListOptions describes what a list endpoint accepts
+type ListOptions struct {
+	Table       string            // table the id and created_at columns belong to
+	Sorts       map[string]string // accepted ?sort= keys and the SQL they order by
+	DefaultSort string            // e.g. "-created_at"; a leading "-" sorts descending
+}
+
+// ListParams is a parsed list request
+type ListParams struct {
+	Limit  int
+	Page   int // offset mode when > 0
+	cursor *listCursor
+	sort   string
+	desc   bool
+	opts   ListOptions
+}
+
+// ListPage is the envelope every list endpoint returns. NextCursor is null on the last
+// page and in offset mode; Page is only set in offset mode.
+type ListPage[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int64   `json:"total"`
+	Limit      int     `json:"limit"`
+	Page       int     `json:"page,omitempty"`
+}
+
+// listCursor is the keyset position after the last row of a page. It is sent to clients
+// base64 encoded and should be treated by them as opaque.
+type listCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Desc      bool      `json:"d"`
+}
+
+// ParseListParams reads ?limit=, ?cursor=, ?page= and ?sort= from the query string.
+// The returned error is safe to show to the client.
+func ParseListParams(c *fiber.Ctx, opts ListOptions) (ListParams, error) {
+	params := ListParams{Limit: c.QueryInt("limit", DefaultPageSize), opts: opts}
+	if params.Limit < 1 {
+		params.Limit = DefaultPageSize
+	}
+	if params.Limit > MaxPageSize {
+		params.Limit = MaxPageSize
+	}
+
+	sortParam := c.Query("sort", opts.DefaultSort)
+	params.sort = strings.TrimPrefix(sortParam, "-")
+	params.desc = strings.HasPrefix(sortParam, "-")
+	if _, ok := opts.Sorts[params.sort]; !ok {
+		keys := make([]string, 0, len(opts.Sorts))
+		for key := range opts.Sorts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return params, fmt.Errorf("Invalid sort, must be one of %s (prefix with - for descending)", strings.Join(keys, ", "))
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		if c.Query("page") != "" {
+			return params, errors.New("Use either cursor or page, not both")
+		}
+		if params.sort != cursorSort {
+			return params, errors.New("Cursors only work with sort=created_at or sort=-created_at, use page instead")
+		}
+		cursor, err := decodeListCursor(value)
+		if err != nil || cursor.Desc != params.desc {
+			return params, errors.New("Invalid cursor")
+		}
+		params.cursor = &cursor
+	} else if c.Query("page") != "" || params.sort != cursorSort {
+		params.Page = c.QueryInt("page", 1)
+		if params.Page < 1 {
+			params.Page = 1
+		}
+	}
+
+	return params, nil
+}
+
+// Paginate runs a filtered query and returns one page of it. key returns the created_at
+// and id of a row, which become the next cursor.
+func Paginate[T any](query *gorm.DB, params ListParams, key func(T) (time.Time, string)) (ListPage[T], error) {
+	page := ListPage[T]{Data: []T{}, Limit: params.Limit, Page: params.Page}
+	query = query.Session(&gorm.Session{})
+
+	if err := query.Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	direction := "ASC"
+	if params.desc {
+		direction = "DESC"
+	}
+	idColumn := params.opts.Table + ".id"
+	rows := query.Order(fmt.Sprintf("%s %s, %s %s", params.opts.Sorts[params.sort], direction, idColumn, direction))
+
+	if params.cursor != nil {
+		createdAt := params.opts.Sorts[cursorSort]
+		operator := ">"
+		if params.desc {
+			operator = "<"
+		}
+		rows = rows.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", createdAt, idColumn, operator), params.cursor.CreatedAt, params.cursor.ID)
+	}
+	if params.Page > 0 {
+		rows = rows.Offset((params.Page - 1) * params.Limit)
+	}
+
+	// One extra row tells whether there is a next page
+	var items []T
+	if err := rows.Limit(params.Limit + 1).Find(&items).Error; err != nil {
+		return page, err
+	}
+	if len(items) > params.Limit {
+		items = items[:params.Limit]
+		if params.Page == 0 {
+			createdAt, id := key(items[len(items)-1])
+			next := encodeListCursor(listCursor{CreatedAt: createdAt, ID: id, Desc: params.desc})
+			page.NextCursor = &next
+		}
+	}
+	page.Data = items
+
+	return page, nil
+}
+
+// MapListPage converts the rows of a page, keeping its pagination fields
+func MapListPage[T, R any](page ListPage[T], convert func(T) R) ListPage[R] {
+	result := ListPage[R]{Data: make([]R, 0, len(page.Data)), NextCursor: page.NextCursor, Total: page.Total, Limit: page.Limit, Page: page.Page}
+	for _, item := range page.Data {
+		result.Data = append(result.Data, convert(item))
+	}
+	return result
+}
+
+// ParseDateParam reads a ?from=/?to= style parameter, either RFC3339 or YYYY-MM-DD.
+// endOfDay moves a plain date to the end of that day, for inclusive upper bounds.
+func ParseDateParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid date %q, use RFC3339 or YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func encodeListCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(value string) (listCursor, error) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
@@ -0,0 +1,50 @@
+package helpers
+
+import (
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+// RoleHasPermissions reports whether the admin role holds every given permission.
+// super_admin always does.
+func RoleHasPermissions(role string, permissions ...string) (bool, error) {
+	if role == models.RoleSuperAdmin {
+		return true, nil
+	}
+	if len(permissions) == 0 {
+		return true, nil
+	}
+
+	var count int64
+	err := database.DB.Model(&models.RolePermission{}).
+		Where("role = ? AND permission IN ?", role, permissions).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count == int64(len(permissions)), nil
+}
+
+// RolePermissions returns the permissions granted to the admin role
+func RolePermissions(role string) ([]string, error) {
+	if role == models.RoleSuperAdmin {
+		return models.AllPermissions, nil
+	}
+
+	var permissions []string
+	err := database.DB.Model(&models.RolePermission{}).
+		Where("role = ?", role).
+		Order("permission").
+		Pluck("permission", &permissions).Error
+	return permissions, err
+}
+
+// RoleExists reports whether the admin role is super_admin or has permissions configured
+func RoleExists(role string) bool {
+	if role == models.RoleSuperAdmin {
+		return true
+	}
+	var count int64
+	database.DB.Model(&models.RolePermission{}).Where("role = ?", role).Count(&count)
+	return count > 0
+}
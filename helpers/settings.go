@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"gorm.io/gorm/clause"
+)
+
+// GetSetting returns the stored value for key, or fallback if it was never set
+func GetSetting(key, fallback string) string {
+	var setting models.Setting
+	if err := database.DB.First(&setting, "key = ?", key).Error; err != nil {
+		return fallback
+	}
+	return setting.Value
+}
+
+// SetSetting creates or updates the value for key
+func SetSetting(key, value string) error {
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&models.Setting{Key: key, Value: value}).Error
+}
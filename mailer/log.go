@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"log"
+	"sync"
+)
+
+// LogMailer writes messages to the log and keeps them in memory instead of
+// sending them. It is meant for development and tests only: the bodies it logs
+// contain password reset and verification links.
+type LogMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	m.mu.Lock()
+	m.sent = append(m.sent, msg)
+	m.mu.Unlock()
+
+	log.Printf("mail to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// Messages returns a copy of every message sent so far
+func (m *LogMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
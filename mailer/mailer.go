@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default is the mailer used by Send. It refuses to send until Init configures a transport,
+// so reset and verification links never end up in the logs by accident.
+var Default Mailer = unconfigured{}
+
+// Init selects the mailer from MAIL_DRIVER, "smtp" or "log". There is no default: the log
+// driver writes every message, reset links included, to the log, so it has to be chosen.
+func Init() error {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "":
+		return errors.New("MAIL_DRIVER is not set, use smtp (or log for development)")
+	case "log":
+		Default = NewLogMailer()
+	case "smtp":
+		m, err := NewSMTPMailerFromEnv()
+		if err != nil {
+			return err
+		}
+		Default = m
+	default:
+		return fmt.Errorf("unknown MAIL_DRIVER: %s", driver)
+	}
+	return nil
+}
+
+// unconfigured is the Default mailer before Init
+type unconfigured struct{}
+
+func (unconfigured) Send(Message) error {
+	return errors.New("mailer not configured")
+}
+
+// Send delivers msg through the Default mailer
+func Send(msg Message) error {
+	return Default.Send(msg)
+}
@@ -0,0 +1,29 @@
+package mailer
+
+import "testing"
+
+func TestInitRequiresADriver(t *testing.T) {
+	previous := Default
+	t.Cleanup(func() { Default = previous })
+
+	if err := (unconfigured{}).Send(Message{To: "someone@example.com"}); err == nil {
+		t.Error("the mailer sent before Init")
+	}
+
+	t.Setenv("MAIL_DRIVER", "")
+	if err := Init(); err == nil {
+		t.Error("Init accepted an unset MAIL_DRIVER")
+	}
+	t.Setenv("MAIL_DRIVER", "carrier-pigeon")
+	if err := Init(); err == nil {
+		t.Error("Init accepted an unknown MAIL_DRIVER")
+	}
+
+	t.Setenv("MAIL_DRIVER", "log")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Default.(*LogMailer); !ok {
+		t.Errorf("MAIL_DRIVER=log: Default is %T", Default)
+	}
+}
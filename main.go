@@ -8,23 +8,48 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/mailer"
 	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/oauth"
 	"github.com/nurullahgd/main-blog-backend/routes"
+	"github.com/nurullahgd/main-blog-backend/scheduler"
+	"github.com/nurullahgd/main-blog-backend/tokens"
 	"github.com/nurullahgd/main-blog-backend/utils"
 )
 
 func main() {
-	// Initialize database
+	// Initialize database (also migrates the schema)
 	database.InitDB()
 
-	// Auto Migrate the schema
-	database.DB.AutoMigrate(&models.User{}, &models.Blog{}, &models.AdminUser{})
+	// Maintenance commands run against the database and exit
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1])
+		return
+	}
 
 	// Initialize Cloudinary
 	if err := utils.InitCloudinary(); err != nil {
 		log.Fatal("Failed to initialize Cloudinary:", err)
 	}
 
+	// Initialize JWT signing keys
+	if err := tokens.Init(); err != nil {
+		log.Fatal("Failed to initialize token keys:", err)
+	}
+
+	// Initialize mailer
+	if err := mailer.Init(); err != nil {
+		log.Fatal("Failed to initialize mailer:", err)
+	}
+
+	// Initialize social login providers
+	if err := oauth.Init(); err != nil {
+		log.Fatal("Failed to initialize OAuth providers:", err)
+	}
+
+	// Publish scheduled posts in the background
+	scheduler.Start()
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:                 "Blog API v1.0",
@@ -38,8 +63,9 @@ func main() {
 	// CORS configuration
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:8000", // Frontend domaininizi buraya ekleyin
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-CSRF-Token, If-Match",
+		ExposeHeaders:    "ETag",
 		AllowCredentials: true,  // Important for cookies
 		MaxAge:           43200, // 12 hours in seconds
 	}))
@@ -56,3 +82,18 @@ func main() {
 	// Start server
 	log.Fatal(app.Listen(":" + port))
 }
+
+// runCommand runs a maintenance command, e.g. `./main backfill-stats`
+func runCommand(name string) {
+	switch name {
+	case "backfill-stats":
+		// Re-render every post, recomputing HTML, plain text, word count, reading time and table of contents
+		updated, err := database.RenderBlogs(database.DB.Unscoped().Model(&models.Blog{}))
+		if err != nil {
+			log.Fatal("Failed to backfill blog stats:", err)
+		}
+		log.Printf("Backfilled stats for %d posts", updated)
+	default:
+		log.Fatalf("Unknown command %q (available: backfill-stats)", name)
+	}
+}
@@ -1,56 +1,40 @@
 package middleware
 
 import (
-	"os"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
 	"github.com/nurullahgd/main-blog-backend/models"
+	"github.com/nurullahgd/main-blog-backend/tokens"
 )
 
+// sessionTouchInterval throttles last_seen_at writes to one per interval per session
+const sessionTouchInterval = time.Minute
+
+// AuthMiddleware accepts either the user_token cookie or an Authorization: Bearer
+// header carrying a personal access token or an access token JWT
 func AuthMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get token from cookie
-		token := c.Cookies("user_token")
-		if token == "" {
+		if !hasCredentials(c) {
 			return c.Status(401).JSON(fiber.Map{
 				"error": "Unauthorized - No token provided",
 			})
 		}
 
-		// Parse and validate token
-		claims := jwt.MapClaims{}
-		parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !parsedToken.Valid {
-			return c.Status(401).JSON(fiber.Map{
-				"error": "Unauthorized - Invalid token",
-			})
-		}
-
-		// Get user ID from token
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			return c.Status(401).JSON(fiber.Map{
-				"error": "Unauthorized - Invalid token claims",
-			})
-		}
-
-		// Check if user exists
-		var user models.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			return c.Status(401).JSON(fiber.Map{
-				"error": "Unauthorized - User not found",
-			})
+		// CSRFProtection may have authenticated the bearer already
+		if !isAuthenticated(c) {
+			if err := authenticateRequest(c); err != nil {
+				return c.Status(401).JSON(fiber.Map{
+					"error": "Unauthorized - " + err.Error(),
+				})
+			}
 		}
 
-		// Add user to context
-		c.Locals("user", user)
-		c.Locals("userID", userID)
-
 		return c.Next()
 	}
 }
@@ -58,28 +42,132 @@ func AuthMiddleware() fiber.Handler {
 // OptionalAuthMiddleware is like AuthMiddleware but doesn't require authentication
 func OptionalAuthMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		token := c.Cookies("user_token")
-		if token == "" {
-			return c.Next()
+		if hasCredentials(c) && !isAuthenticated(c) {
+			authenticateRequest(c)
 		}
 
-		claims := jwt.MapClaims{}
-		parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err == nil && parsedToken.Valid {
-			if userID, ok := claims["user_id"].(string); ok {
-				var user models.User
-				if err := database.DB.First(&user, "id = ?", userID).Error; err == nil {
-					c.Locals("user", user)
-					c.Locals("userID", userID)
-				}
-			}
+		return c.Next()
+	}
+}
+
+func hasCredentials(c *fiber.Ctx) bool {
+	return bearerToken(c) != "" || c.Cookies("user_token") != ""
+}
+
+// isAuthenticated reports whether authenticateRequest already resolved the caller
+func isAuthenticated(c *fiber.Ctx) bool {
+	return c.Locals("authMethod") != nil
+}
+
+func bearerToken(c *fiber.Ctx) string {
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// authenticateRequest resolves the caller and stores it in the context locals:
+// "user", "userID", "authMethod" and either "sessionID" or "tokenScopes". authMethod
+// is only set once the caller is authenticated, and says which credential did it.
+func authenticateRequest(c *fiber.Ctx) error {
+	token := bearerToken(c)
+	authMethod := "bearer"
+	if token == "" {
+		token = c.Cookies("user_token")
+		authMethod = "cookie"
+	}
+
+	if strings.HasPrefix(token, models.PersonalAccessTokenPrefix) {
+		user, scopes, err := authenticateAccessToken(token)
+		if err != nil {
+			return err
 		}
+		c.Locals("user", user)
+		c.Locals("userID", user.ID.String())
+		c.Locals("authMethod", authMethod)
+		c.Locals("tokenScopes", scopes)
+		return nil
+	}
 
-		return c.Next()
+	user, sessionID, err := authenticateUserToken(token)
+	if err != nil {
+		return err
+	}
+
+	// Add user to context
+	c.Locals("user", user)
+	c.Locals("userID", user.ID.String())
+	c.Locals("authMethod", authMethod)
+	c.Locals("sessionID", sessionID)
+	return nil
+}
+
+// authenticateAccessToken validates a personal access token and returns its owner and scopes
+func authenticateAccessToken(token string) (models.User, []string, error) {
+	var user models.User
+
+	var pat models.PersonalAccessToken
+	if err := database.DB.Where("token_hash = ?", helpers.HashToken(token)).First(&pat).Error; err != nil {
+		return user, nil, errors.New("Invalid token")
+	}
+	if pat.RevokedAt != nil || (pat.ExpiresAt != nil && time.Now().After(*pat.ExpiresAt)) {
+		return user, nil, errors.New("Token expired or revoked")
+	}
+
+	if err := database.DB.First(&user, "id = ?", pat.UserID).Error; err != nil {
+		return user, nil, errors.New("User not found")
+	}
+
+	if pat.LastUsedAt == nil || time.Since(*pat.LastUsedAt) > sessionTouchInterval {
+		database.DB.Model(&pat).UpdateColumn("last_used_at", time.Now())
+	}
+
+	return user, strings.Fields(pat.Scopes), nil
+}
+
+// authenticateUserToken validates an access token and the session it belongs to.
+// The returned error is safe to show to the client.
+func authenticateUserToken(token string) (models.User, string, error) {
+	var user models.User
+
+	// Parse and validate token
+	claims := jwt.MapClaims{}
+	parsedToken, err := tokens.Parse(token, claims, jwt.WithAudience(tokens.AudienceAccess), jwt.WithIssuer(tokens.Issuer))
+	// The purpose check keeps 2FA challenges and other signed tokens from being used as access tokens
+	if err != nil || !parsedToken.Valid || claims["purpose"] != "access" {
+		return user, "", errors.New("Invalid token")
+	}
+
+	// Get user and session IDs from token
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return user, "", errors.New("Invalid token claims")
+	}
+	sessionID, ok := claims["sid"].(string)
+	if !ok {
+		return user, "", errors.New("Invalid token claims")
+	}
+
+	// Reject tokens whose session was revoked, even if the JWT hasn't expired yet
+	var session models.Session
+	if err := database.DB.First(&session, "id = ? AND user_id = ?", sessionID, userID).Error; err != nil {
+		return user, "", errors.New("Session not found")
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return user, "", errors.New("Session revoked")
+	}
+
+	// Check if user exists
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return user, "", errors.New("User not found")
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		database.DB.Model(&session).UpdateColumn("last_seen_at", time.Now())
 	}
+
+	return user, sessionID, nil
 }
 
 // CheckUserID checks if the requested user ID matches the authenticated user's ID
@@ -108,20 +196,34 @@ func AdminAuthMiddleware() fiber.Handler {
 		}
 
 		claims := jwt.MapClaims{}
-		parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err == nil && parsedToken.Valid {
-			if userID, ok := claims["user_id"].(string); ok {
-				var user models.AdminUser
-				if err := database.DB.First(&user, "id = ?", userID).Error; err == nil {
-					c.Locals("user", user)
-					c.Locals("userID", userID)
-				}
-			}
+		parsedToken, err := tokens.Parse(token, claims, jwt.WithAudience(tokens.AudienceAdmin), jwt.WithIssuer(tokens.Issuer))
+
+		// Without the purpose check a 2FA challenge would pass as an admin token
+		if err != nil || !parsedToken.Valid || claims["purpose"] != "admin_access" {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Unauthorized - Invalid token",
+			})
+		}
+
+		adminID, ok := claims["user_id"].(string)
+		if !ok {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Unauthorized - Invalid token claims",
+			})
 		}
 
+		// Load the admin so role changes and deletions take effect immediately
+		var admin models.AdminUser
+		if err := database.DB.First(&admin, "id = ?", adminID).Error; err != nil {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Unauthorized - Admin not found",
+			})
+		}
+
+		c.Locals("admin", admin)
+		c.Locals("adminID", adminID)
+		c.Locals("adminRole", admin.Role)
+
 		return c.Next()
 	}
 }
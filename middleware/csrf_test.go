@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	tests := []struct {
+		name          string
+		bearer        string
+		authenticated bool // the bearer resolved to a user before the check
+		cookies       bool
+		csrfHeader    string
+		want          int
+	}{
+		{"cookie without token", "", false, true, "", fiber.StatusForbidden},
+		{"cookie with wrong token", "", false, true, "other", fiber.StatusForbidden},
+		{"cookie with token", "", false, true, "csrf-value", fiber.StatusOK},
+		{"no credentials", "", false, false, "", fiber.StatusOK},
+		{"authenticated bearer", "token", true, true, "", fiber.StatusOK},
+		// A made-up header must not exempt the cookies the browser attached
+		{"invalid bearer with cookie", "not-a-token", false, true, "", fiber.StatusForbidden},
+		{"invalid bearer with cookie and token", "not-a-token", false, true, "csrf-value", fiber.StatusOK},
+		{"invalid bearer without cookie", "not-a-token", false, false, "", fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			if tt.authenticated {
+				c.Locals("authMethod", "bearer")
+			}
+			return c.Next()
+		})
+		app.Use(CSRFProtection())
+		app.Post("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+		req := httptest.NewRequest(fiber.MethodPost, "/", nil)
+		if tt.bearer != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.bearer)
+		}
+		if tt.cookies {
+			req.Header.Set(fiber.HeaderCookie, "user_token=session; "+helpers.CSRFCookieName+"=csrf-value")
+		}
+		if tt.csrfHeader != "" {
+			req.Header.Set(helpers.CSRFHeaderName, tt.csrfHeader)
+		}
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+// RequirePermission rejects admins whose role lacks any of the given permissions.
+// It must run after AdminAuthMiddleware.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, ok := c.Locals("adminRole").(string)
+		if !ok {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Unauthorized - No admin session",
+			})
+		}
+
+		allowed, err := helpers.RoleHasPermissions(role, permissions...)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to check permissions",
+			})
+		}
+		if !allowed {
+			return c.Status(403).JSON(fiber.Map{
+				"error":    "Forbidden - Missing permission",
+				"required": permissions,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireAdminTwoFactor blocks admins without 2FA while a super_admin has made it mandatory.
+// It must run after AdminAuthMiddleware.
+func RequireAdminTwoFactor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		admin, ok := c.Locals("admin").(models.AdminUser)
+		if !ok {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Unauthorized - No admin session",
+			})
+		}
+
+		if !admin.TOTPEnabled && helpers.GetSetting(models.SettingAdminRequire2FA, "false") == "true" {
+			return c.Status(403).JSON(fiber.Map{
+				"error":                     "Forbidden - Two-factor authentication setup required",
+				"two_factor_setup_required": true,
+			})
+		}
+
+		return c.Next()
+	}
+}
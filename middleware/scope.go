@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireScope rejects personal access tokens that lack the given scope.
+// Browser sessions are not scoped and always pass. It must run after AuthMiddleware.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals("tokenScopes").([]string)
+		if !ok {
+			return c.Next()
+		}
+
+		for _, s := range scopes {
+			if s == scope {
+				return c.Next()
+			}
+		}
+
+		return c.Status(403).JSON(fiber.Map{
+			"error":    "Forbidden - Token is missing scope",
+			"required": scope,
+		})
+	}
+}
+
+// RequireSession rejects personal access tokens, for account management routes
+// that must only be reachable from a logged in browser session.
+// It must run after AuthMiddleware.
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("sessionID").(string); !ok {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Forbidden - This endpoint requires a logged in session",
+			})
+		}
+
+		return c.Next()
+	}
+}
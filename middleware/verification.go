@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+// RequireVerifiedEmail blocks users with an unverified email when
+// REQUIRE_EMAIL_VERIFICATION is "true". It must run after AuthMiddleware.
+func RequireVerifiedEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if os.Getenv("REQUIRE_EMAIL_VERIFICATION") != "true" {
+			return c.Next()
+		}
+
+		user, ok := c.Locals("user").(models.User)
+		if !ok {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Unauthorized - No user session",
+			})
+		}
+		if !user.EmailVerified {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Forbidden - Please verify your email address first",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessTokenPrefix marks bearer tokens that are personal access tokens rather than JWTs
+const PersonalAccessTokenPrefix = "blog_pat_"
+
+// Personal access token scopes
+const (
+	ScopeBlogsRead    = "blogs:read"
+	ScopeBlogsWrite   = "blogs:write"
+	ScopeMediaWrite   = "media:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+// AllScopes lists every scope a personal access token can be granted
+var AllScopes = []string{
+	ScopeBlogsRead,
+	ScopeBlogsWrite,
+	ScopeMediaWrite,
+	ScopeProfileWrite,
+}
+
+// PersonalAccessToken lets API clients authenticate with an Authorization: Bearer header.
+// Only the SHA-256 hash of the token is stored.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID     string     `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name       string     `json:"name" gorm:"not null"`
+	TokenHash  string     `json:"-" gorm:"not null;unique"`
+	Prefix     string     `json:"prefix" gorm:"not null"` // first characters, to recognise the token in lists
+	Scopes     string     `json:"scopes" gorm:"not null"` // space separated
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// PersonalAccessTokenCreate represents the data needed to create a personal access token
+type PersonalAccessTokenCreate struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 means the token never expires
+}
+
+// PersonalAccessTokenResponse represents a personal access token in responses.
+// Token is only set once, in the create response.
+type PersonalAccessTokenResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
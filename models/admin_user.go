@@ -7,15 +7,23 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "super_admin"
+)
+
 type AdminUser struct {
-	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	Username  string         `json:"username" gorm:"not null;unique"`
-	Email     string         `json:"email" gorm:"not null;unique"`
-	Password  string         `json:"-" gorm:"not null"`                            // Password is not exposed in JSON
-	Role      string         `json:"role" gorm:"type:varchar(20);default:'admin'"` // admin, super_admin
-	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Username     string         `json:"username" gorm:"not null;unique"`
+	Email        string         `json:"email" gorm:"not null;unique"`
+	Password     string         `json:"-" gorm:"not null"`                            // Password is not exposed in JSON
+	Role         string         `json:"role" gorm:"type:varchar(20);default:'admin'"` // admin, super_admin or a custom role
+	TOTPSecret   string         `json:"-"`
+	TOTPEnabled  bool           `json:"two_factor_enabled" gorm:"default:false"`
+	TOTPLastStep int64          `json:"-" gorm:"default:0"`
+	CreatedAt    time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt    gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 // AdminUserCreate represents the data needed to create a new admin user
@@ -23,15 +31,22 @@ type AdminUserCreate struct {
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required,oneof=admin super_admin"`
+	Role     string `json:"role" binding:"required"` // admin, super_admin or a custom role
+}
+
+// AdminLogin represents the credentials used by the admin login endpoint
+type AdminLogin struct {
+	Input    string `json:"input" binding:"required"` // username or email
+	Password string `json:"password" binding:"required"`
 }
 
 // AdminUserResponse represents the admin user data that will be sent in responses
 type AdminUserResponse struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID               string    `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
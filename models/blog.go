@@ -1,25 +1,104 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/content"
 	"gorm.io/gorm"
 )
 
+// Blog statuses. Only published posts are listed; unlisted posts are readable by anyone with the link.
+const (
+	BlogStatusDraft     = "draft"
+	BlogStatusScheduled = "scheduled"
+	BlogStatusPublished = "published"
+	BlogStatusArchived  = "archived"
+	BlogStatusUnlisted  = "unlisted"
+)
+
+// AllBlogStatuses lists every valid blog status
+var AllBlogStatuses = []string{
+	BlogStatusDraft,
+	BlogStatusScheduled,
+	BlogStatusPublished,
+	BlogStatusArchived,
+	BlogStatusUnlisted,
+}
+
+// DefaultBlogLanguage is the text search configuration of posts that don't pick one.
+// It lowercases words without stemming them.
+const DefaultBlogLanguage = "simple"
+
+// SearchLanguages lists the Postgres text search configurations a post can be indexed with
+var SearchLanguages = []string{
+	DefaultBlogLanguage,
+	"english",
+	"turkish",
+	"german",
+	"french",
+	"spanish",
+	"italian",
+	"russian",
+}
+
 type Blog struct {
-	ID         uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	Title      string         `json:"title" gorm:"not null"`
-	Content    string         `json:"content" gorm:"type:text;not null"`
-	Slug       string         `json:"slug" gorm:"not null;unique"`
-	MainImage  string         `json:"main_image" gorm:"default:null"`
-	UserID     string         `json:"user_id" gorm:"type:uuid;not nullc"`
-	Visibility bool           `json:"visibility" gorm:"default:true"`
-	Category   string         `json:"category" gorm:"not null"`
-	Summary    string         `json:"summary" gorm:"not null"`
-	CreatedAt  time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt  time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt  gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ID              uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Title           string          `json:"title" gorm:"not null"`
+	Content         string          `json:"content" gorm:"type:text;not null"`
+	ContentFormat   string          `json:"content_format" gorm:"type:varchar(10);not null;default:'markdown'"`
+	Language        string          `json:"language" gorm:"type:varchar(20);not null;default:'simple'"`
+	ContentHTML     *string         `json:"content_html" gorm:"type:text"` // rendered and sanitized on save, NULL until rendered
+	ContentText     *string         `json:"-" gorm:"type:text"`            // plain text for summaries and search, NULL until rendered
+	WordCount       int             `json:"word_count" gorm:"not null;default:0"`
+	ReadingTime     int             `json:"reading_time" gorm:"not null;default:0"` // minutes
+	TableOfContents TableOfContents `json:"table_of_contents" gorm:"type:jsonb"`    // NULL until rendered
+	Slug            string          `json:"slug" gorm:"not null;unique"`
+	MainImage       string          `json:"main_image" gorm:"default:null"`
+	UserID          string          `json:"user_id" gorm:"type:uuid;not nullc"`
+	Visibility      bool            `json:"visibility" gorm:"default:true"` // kept in sync with Status for older clients
+	CategoryID      *uuid.UUID      `json:"category_id" gorm:"type:uuid;index"`
+	Summary         string          `json:"summary" gorm:"not null"`
+	Status          string          `json:"status" gorm:"type:varchar(20);index"`
+	PublishedAt     *time.Time      `json:"published_at"`
+	ScheduledAt     *time.Time      `json:"scheduled_at" gorm:"index"`
+	RevisionLimit   int             `json:"revision_limit" gorm:"not null;default:0"` // 0 means the server default
+	Version         int             `json:"version" gorm:"not null;default:1"`
+	CreatedAt       time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt  `json:"deleted_at,omitempty" gorm:"index"`
+	Tags            []Tag           `json:"tags,omitempty" gorm:"-"`     // loaded from blog_tags when needed
+	Category        *Category       `json:"category,omitempty" gorm:"-"` // loaded from CategoryID when needed
+	Author          *User           `json:"-" gorm:"-"`                  // loaded from UserID for ?include=author
+}
+
+// TableOfContents lists a post's headings, stored as JSON
+type TableOfContents []content.Heading
+
+// Value implements driver.Valuer
+func (t TableOfContents) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner
+func (t *TableOfContents) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("cannot scan %T into TableOfContents", value)
+	}
 }
 
 // BlogCreate represents the data needed to create a new blog
@@ -30,7 +109,7 @@ type BlogCreate struct {
 	MainImage  string    `json:"main_image"`
 	UserID     string    `json:"user_id" binding:"required"`
 	Visibility bool      `json:"visibility" binding:"required"`
-	Category   string    `json:"category" binding:"required"`
+	Category   string    `json:"category"` // category slug
 	Summary    string    `json:"summary" binding:"required"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
@@ -38,15 +117,79 @@ type BlogCreate struct {
 
 // BlogResponse represents the blog data that will be sent in responses
 type BlogResponse struct {
-	ID         string    `json:"id"`
-	Title      string    `json:"title"`
-	Content    string    `json:"content"`
-	Slug       string    `json:"slug"`
-	MainImage  string    `json:"main_image"`
-	UserID     string    `json:"user_id"`
-	Category   string    `json:"category"`
-	Visibility bool      `json:"visibility"`
-	Summary    string    `json:"summary"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID              string           `json:"id"`
+	Title           string           `json:"title"`
+	Content         string           `json:"content"`
+	ContentFormat   string           `json:"content_format"`
+	Language        string           `json:"language"`
+	ContentHTML     string           `json:"content_html"`
+	Blocks          json.RawMessage  `json:"blocks,omitempty"` // the parsed document when content_format is blocks
+	WordCount       int              `json:"word_count"`
+	ReadingTime     int              `json:"reading_time"`
+	TableOfContents TableOfContents  `json:"table_of_contents"`
+	Tags            []TagResponse    `json:"tags"`
+	Slug            string           `json:"slug"`
+	MainImage       string           `json:"main_image"`
+	UserID          string           `json:"user_id"`
+	Author          *AuthorResponse  `json:"author,omitempty"` // only with ?include=author
+	Category        *CategorySummary `json:"category"`
+	Visibility      bool             `json:"visibility"`
+	Summary         string           `json:"summary"`
+	Status          string           `json:"status"`
+	PublishedAt     *time.Time       `json:"published_at"`
+	ScheduledAt     *time.Time       `json:"scheduled_at"`
+	Version         int              `json:"version"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+// BlogCard is the compact form of a post returned by list endpoints unless ?fields= asks
+// for something else
+type BlogCard struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Slug        string           `json:"slug"`
+	Summary     string           `json:"summary"`
+	MainImage   string           `json:"main_image"`
+	UserID      string           `json:"user_id"`
+	Author      *AuthorResponse  `json:"author,omitempty"` // only with ?include=author
+	Category    *CategorySummary `json:"category"`
+	Tags        []TagResponse    `json:"tags"`
+	WordCount   int              `json:"word_count"`
+	ReadingTime int              `json:"reading_time"`
+	Status      string           `json:"status"`
+	PublishedAt *time.Time       `json:"published_at"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// BlogSearchResult is a search hit: the post's card with a highlighted snippet of its
+// content, where matches are wrapped in <mark>, and its rank
+type BlogSearchResult struct {
+	BlogCard
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// BlogPatch represents a partial update. Nil fields are left unchanged; in JSON
+// merge-patch bodies, null clears the optional fields (summary, category). Category is a slug.
+type BlogPatch struct {
+	Title          *string    `json:"title"`
+	Content        *string    `json:"content"`
+	ContentFormat  *string    `json:"content_format"`
+	Blocks         *string    `json:"blocks"` // a block document; sets content_format to blocks
+	Language       *string    `json:"language"`
+	Summary        *string    `json:"summary"`
+	Category       *string    `json:"category"`
+	Status         *string    `json:"status"`
+	ScheduledAt    *time.Time `json:"scheduled_at"`
+	Visibility     *bool      `json:"visibility"` // for older clients, ignored when status is set
+	Slug           *string    `json:"slug"`
+	Tags           *[]string  `json:"tags"`            // replaces all tags; null or [] removes them
+	RegenerateSlug bool       `json:"regenerate_slug"` // rebuild the slug from the (new) title
+}
+
+// BlogStatusUpdate represents a status change. ScheduledAt is required for scheduled posts.
+type BlogStatusUpdate struct {
+	Status      string     `json:"status" binding:"required"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
 }
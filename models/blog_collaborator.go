@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Collaborator roles. Co-authors can edit and publish a post, editors can only edit it.
+const (
+	CollaboratorRoleCoAuthor = "coauthor"
+	CollaboratorRoleEditor   = "editor"
+)
+
+// BlogCollaborator grants a user other than the owner access to a post
+type BlogCollaborator struct {
+	BlogID    uuid.UUID `json:"blog_id" gorm:"type:uuid;primaryKey"`
+	UserID    string    `json:"user_id" gorm:"type:uuid;primaryKey;index"`
+	Role      string    `json:"role" gorm:"type:varchar(20);not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BlogCollaboratorCreate represents the data needed to add a collaborator
+type BlogCollaboratorCreate struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// BlogCollaboratorResponse represents a collaborator in responses
+type BlogCollaboratorResponse struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BlogRevision is a snapshot of a post's content taken on every save
+type BlogRevision struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	BlogID        uuid.UUID `json:"blog_id" gorm:"type:uuid;not null;uniqueIndex:idx_blog_revision_number"`
+	Number        int       `json:"number" gorm:"not null;uniqueIndex:idx_blog_revision_number"`
+	Title         string    `json:"title" gorm:"not null"`
+	Content       string    `json:"content" gorm:"type:text;not null"`
+	ContentFormat string    `json:"content_format" gorm:"type:varchar(10);not null;default:'markdown'"`
+	Summary       string    `json:"summary" gorm:"not null"`
+	Category      string    `json:"category" gorm:"not null"` // category slug at the time
+	AuthorID      string    `json:"author_id" gorm:"type:uuid"`
+	AuthorType    string    `json:"author_type" gorm:"type:varchar(10);not null"` // OwnerTypeUser or OwnerTypeAdmin
+	RestoredFrom  *int      `json:"restored_from"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BlogRevisionSummary represents a revision in list responses, without its content
+type BlogRevisionSummary struct {
+	Number       int       `json:"number"`
+	Title        string    `json:"title"`
+	AuthorID     string    `json:"author_id"`
+	AuthorType   string    `json:"author_type"`
+	RestoredFrom *int      `json:"restored_from"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BlogRevisionRetention sets how many revisions a post keeps. 0 restores the default.
+type BlogRevisionRetention struct {
+	Limit int `json:"limit"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Category is a node in the category tree. Siblings are ordered by Position, then name.
+type Category struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	ParentID    *uuid.UUID `json:"parent_id" gorm:"type:uuid;index"`
+	Name        string     `json:"name" gorm:"not null"`
+	Slug        string     `json:"slug" gorm:"not null;uniqueIndex"`
+	Description string     `json:"description" gorm:"type:text;not null;default:''"`
+	CoverImage  string     `json:"cover_image" gorm:"default:null"`
+	Position    int        `json:"position" gorm:"not null;default:0"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// CategoryInput represents the data for creating or updating a category. Nil fields are
+// left unchanged on update; an empty parent_id moves the category to the top level.
+type CategoryInput struct {
+	Name        *string `json:"name" form:"name"`
+	Slug        *string `json:"slug" form:"slug"`
+	ParentID    *string `json:"parent_id" form:"parent_id"`
+	Description *string `json:"description" form:"description"`
+	Position    *int    `json:"position" form:"position"`
+}
+
+// CategoryResponse represents a category in responses, with its subtree when listing the tree
+type CategoryResponse struct {
+	ID          string             `json:"id"`
+	ParentID    *string            `json:"parent_id"`
+	Name        string             `json:"name"`
+	Slug        string             `json:"slug"`
+	Description string             `json:"description"`
+	CoverImage  string             `json:"cover_image"`
+	Position    int                `json:"position"`
+	Children    []CategoryResponse `json:"children,omitempty"`
+}
+
+// CategorySummary is the category embedded in blog responses
+type CategorySummary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
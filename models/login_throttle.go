@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one key, e.g. "account:<id>" or "ip:<address>"
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"primaryKey;type:varchar(320)"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
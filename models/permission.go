@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Admin permissions checked by middleware.RequirePermission
+const (
+	PermUsersRead        = "users:read"
+	PermUsersDelete      = "users:delete"
+	PermUsersSessions    = "users:sessions"
+	PermUsersUnlock      = "users:unlock"
+	PermBlogsModerate    = "blogs:moderate"
+	PermCategoriesManage = "categories:manage"
+	PermAdminsRead       = "admins:read"
+	PermAdminsCreate     = "admins:create"
+	PermRolesManage      = "roles:manage"
+	PermSettingsManage   = "settings:manage"
+)
+
+// AllPermissions lists every permission a role can be granted
+var AllPermissions = []string{
+	PermUsersRead,
+	PermUsersDelete,
+	PermUsersSessions,
+	PermUsersUnlock,
+	PermBlogsModerate,
+	PermCategoriesManage,
+	PermAdminsRead,
+	PermAdminsCreate,
+	PermRolesManage,
+	PermSettingsManage,
+}
+
+// DefaultRolePermissions is seeded into role_permissions on first start.
+// super_admin is not listed: it implicitly holds every permission.
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermUsersRead,
+		PermUsersDelete,
+		PermUsersSessions,
+		PermUsersUnlock,
+		PermBlogsModerate,
+		PermCategoriesManage,
+		PermAdminsRead,
+	},
+}
+
+// RolePermission grants a single permission to an admin role
+type RolePermission struct {
+	Role       string    `json:"role" gorm:"primaryKey;type:varchar(20)"`
+	Permission string    `json:"permission" gorm:"primaryKey;type:varchar(50)"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RolePermissionsUpdate represents the data needed to replace a role's permission set
+type RolePermissionsUpdate struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// RoleResponse represents a role and its permissions in responses
+type RoleResponse struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Setting keys
+const (
+	SettingAdminRequire2FA = "admin_require_2fa"
+)
+
+// Setting is a runtime configuration value editable by a super_admin
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;type:varchar(50)"`
+	Value     string    `json:"value" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// AdminTwoFactorSetting represents the data needed to toggle mandatory 2FA for admins
+type AdminTwoFactorSetting struct {
+	Required bool `json:"required"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tag limits, enforced when tags are assigned to a post
+const (
+	MaxTagsPerBlog   = 10
+	MaxTagNameLength = 50
+)
+
+// Tag is a label shared by posts. Slug is the normalized name and identifies the tag.
+type Tag struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Name      string    `json:"name" gorm:"not null"`
+	Slug      string    `json:"slug" gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// BlogTag links a post to one of its tags
+type BlogTag struct {
+	BlogID    uuid.UUID `json:"blog_id" gorm:"type:uuid;primaryKey"`
+	TagID     uuid.UUID `json:"tag_id" gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TagResponse represents a tag in responses. BlogCount is the number of published posts.
+type TagResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	BlogCount *int64 `json:"blog_count,omitempty"`
+}
+
+// TagUpdate represents a rename. The slug is rebuilt from the new name.
+type TagUpdate struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// TagMerge represents merging a tag into another one, given by slug
+type TagMerge struct {
+	Into string `json:"into" binding:"required"`
+}
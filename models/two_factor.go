@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Owner types for RecoveryCode
+const (
+	OwnerTypeUser  = "user"
+	OwnerTypeAdmin = "admin"
+)
+
+// RecoveryCode is a one-time 2FA backup code. Codes are stored as bcrypt hashes.
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	OwnerType string     `json:"owner_type" gorm:"type:varchar(10);not null;index:idx_recovery_owner"`
+	OwnerID   string     `json:"owner_id" gorm:"type:uuid;not null;index:idx_recovery_owner"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TwoFactorAttempt records a second-factor attempt against a login challenge.
+// Rows are counted to cap guesses per challenge and per client IP.
+type TwoFactorAttempt struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	ChallengeID string    `json:"challenge_id" gorm:"type:uuid;not null;index"`
+	IP          string    `json:"ip" gorm:"type:varchar(64);not null;index:idx_two_factor_attempt_ip"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime;index:idx_two_factor_attempt_ip"`
+}
+
+// TwoFactorCode represents a TOTP code used to confirm enrollment
+type TwoFactorCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorLogin represents the second step of a two-factor login.
+// Either Code or RecoveryCode must be set. ChallengeToken may be left out
+// after a social login, which sets the challenge in a cookie instead.
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// TwoFactorDisable represents the data needed to turn off two-factor authentication
+type TwoFactorDisable struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// TwoFactorEnrollment is returned when a user starts 2FA enrollment
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
@@ -8,18 +8,25 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	Name         string         `json:"name" gorm:"not null"`
-	Surname      string         `json:"surname" gorm:"not null"`
-	Username     string         `json:"username" gorm:"not null;unique"`
-	Email        string         `json:"email" gorm:"not null;unique"`
-	Password     []byte         `json:"password" gorm:"not null"`
-	ProfileImage string         `json:"profile_image" gorm:"default:null"`
-	BlogCount    int            `json:"blog_count" gorm:"default:0"`
-	Blogs        []Blog         `json:"blogs,omitempty" gorm:"foreignKey:UserID"`
-	CreatedAt    time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt    time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ID                 uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Name               string         `json:"name" gorm:"not null"`
+	Surname            string         `json:"surname" gorm:"not null"`
+	Username           string         `json:"username" gorm:"not null;unique"`
+	Email              string         `json:"email" gorm:"not null;unique"`
+	Password           []byte         `json:"password" gorm:"not null"`
+	ProfileImage       string         `json:"profile_image" gorm:"default:null"`
+	BlogCount          int            `json:"blog_count" gorm:"default:0"`
+	EmailVerified      bool           `json:"email_verified" gorm:"default:false"`
+	VerifiedAt         *time.Time     `json:"verified_at,omitempty"`
+	VerificationSentAt *time.Time     `json:"-"`
+	TOTPSecret         string         `json:"-"`
+	TOTPEnabled        bool           `json:"two_factor_enabled" gorm:"default:false"`
+	TOTPLastStep       int64          `json:"-" gorm:"default:0"`
+	Version            int            `json:"version" gorm:"not null;default:1"`
+	Blogs              []Blog         `json:"blogs,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt          time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt          gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 // UserCreate represents the data needed to create a new user
@@ -33,15 +40,26 @@ type UserCreate struct {
 
 // UserResponse represents the user data that will be sent in responses
 type UserResponse struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Surname      string    `json:"surname"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	ProfileImage string    `json:"profile_image"`
-	BlogCount    int       `json:"blog_count"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Surname       string    `json:"surname"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	ProfileImage  string    `json:"profile_image"`
+	BlogCount     int       `json:"blog_count"`
+	EmailVerified bool      `json:"email_verified"`
+	Version       int       `json:"version"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// AuthorResponse is the public profile of a post's author
+type AuthorResponse struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	Name         string `json:"name"`
+	Surname      string `json:"surname"`
+	ProfileImage string `json:"profile_image"`
 }
 
 type UserLogin struct {
@@ -54,8 +72,9 @@ type UserEdit struct {
 	Name         string `json:"name" binding:"required"`
 	Surname      string `json:"surname" binding:"required"`
 	ProfileImage string `json:"profile_image" binding:"required"`
-	UpdatedAt    int64  `json:"updated_at" binding:"required"`
+	Version      int    `json:"version"` // expected version, an alternative to If-Match
 }
 type UserPasswordChange struct {
-	Password string `json:"password" binding:"required"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Password        string `json:"password" binding:"required"`
 }
@@ -0,0 +1,371 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider types
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+// Provider is an OAuth2 authorization code provider. OIDC providers fill in
+// their endpoints from the issuer's discovery document on first use.
+type Provider struct {
+	Name         string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	EmailsURL    string // GitHub only: used when the profile has no public email
+	Scopes       []string
+	RedirectURL  string
+
+	mu         sync.Mutex
+	discovered bool
+}
+
+// Identity is the external account returned by a provider
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+}
+
+var (
+	providers  = map[string]*Provider{}
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Init loads providers from OAUTH_PROVIDERS (comma separated names) and the
+// OAUTH_<NAME>_* variables. Every endpoint can be overridden, which is how a
+// local fake provider is wired in for testing.
+func Init() error {
+	providers = map[string]*Provider{}
+
+	names := strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",")
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		env := func(key string) string {
+			return os.Getenv("OAUTH_" + strings.ToUpper(name) + "_" + key)
+		}
+
+		p := &Provider{
+			Name:         name,
+			Type:         env("TYPE"),
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			Issuer:       strings.TrimRight(env("ISSUER"), "/"),
+			AuthURL:      env("AUTH_URL"),
+			TokenURL:     env("TOKEN_URL"),
+			UserInfoURL:  env("USERINFO_URL"),
+			EmailsURL:    env("EMAILS_URL"),
+			RedirectURL:  strings.TrimRight(os.Getenv("OAUTH_REDIRECT_BASE_URL"), "/") + "/api/auth/" + name + "/callback",
+		}
+		if scopes := env("SCOPES"); scopes != "" {
+			p.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+
+		switch p.Type {
+		case TypeOIDC:
+			if p.Issuer == "" && (p.AuthURL == "" || p.TokenURL == "" || p.UserInfoURL == "") {
+				return fmt.Errorf("oauth provider %s: ISSUER or explicit endpoints are required", name)
+			}
+			if p.Scopes == nil {
+				p.Scopes = []string{"openid", "email", "profile"}
+			}
+		case TypeGitHub:
+			setDefault(&p.AuthURL, "https://github.com/login/oauth/authorize")
+			setDefault(&p.TokenURL, "https://github.com/login/oauth/access_token")
+			setDefault(&p.UserInfoURL, "https://api.github.com/user")
+			setDefault(&p.EmailsURL, "https://api.github.com/user/emails")
+			if p.Scopes == nil {
+				p.Scopes = []string{"read:user", "user:email"}
+			}
+		default:
+			return fmt.Errorf("oauth provider %s: unknown type %q", name, p.Type)
+		}
+
+		if p.ClientID == "" {
+			return fmt.Errorf("oauth provider %s: CLIENT_ID is required", name)
+		}
+
+		providers[name] = p
+	}
+	return nil
+}
+
+// Get returns the configured provider with the given name
+func Get(name string) (*Provider, bool) {
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Names returns the configured provider names in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge
+func NewPKCE() (verifier, challenge string) {
+	verifier = randomString()
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewState returns a random value for the OAuth state parameter
+func NewState() string {
+	return randomString()
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to
+func (p *Provider) AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.AuthURL + sep + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for an access token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return "", fmt.Errorf("token exchange failed: %v", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("token exchange failed: %s %s", result.Error, result.Description)
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("token exchange failed: no access token returned")
+	}
+	return result.AccessToken, nil
+}
+
+// FetchIdentity loads the external account for an access token
+func (p *Provider) FetchIdentity(ctx context.Context, accessToken string) (Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return Identity{}, err
+	}
+	if p.Type == TypeGitHub {
+		return p.fetchGitHubIdentity(ctx, accessToken)
+	}
+	return p.fetchOIDCIdentity(ctx, accessToken)
+}
+
+func (p *Provider) fetchOIDCIdentity(ctx context.Context, accessToken string) (Identity, error) {
+	var info struct {
+		Sub               string      `json:"sub"`
+		Email             string      `json:"email"`
+		EmailVerified     interface{} `json:"email_verified"` // some providers send "true" as a string
+		PreferredUsername string      `json:"preferred_username"`
+		Nickname          string      `json:"nickname"`
+		Name              string      `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, accessToken, &info); err != nil {
+		return Identity{}, err
+	}
+	if info.Sub == "" {
+		return Identity{}, fmt.Errorf("userinfo response has no subject")
+	}
+
+	username := info.PreferredUsername
+	if username == "" {
+		username = info.Nickname
+	}
+
+	return Identity{
+		Subject:       info.Sub,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified == true || info.EmailVerified == "true",
+		Username:      username,
+		Name:          info.Name,
+	}, nil
+}
+
+func (p *Provider) fetchGitHubIdentity(ctx context.Context, accessToken string) (Identity, error) {
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, accessToken, &profile); err != nil {
+		return Identity{}, err
+	}
+	if profile.ID == 0 {
+		return Identity{}, fmt.Errorf("profile response has no id")
+	}
+
+	identity := Identity{
+		Subject:  fmt.Sprint(profile.ID),
+		Username: profile.Login,
+		Name:     profile.Name,
+	}
+
+	// The profile email is optional and unverified, so use the primary verified address
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if p.EmailsURL != "" {
+		if err := p.getJSON(ctx, p.EmailsURL, accessToken, &emails); err != nil {
+			return Identity{}, err
+		}
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			identity.Email = e.Email
+			identity.EmailVerified = true
+			break
+		}
+	}
+
+	return identity, nil
+}
+
+// discover fills in OIDC endpoints from the issuer's discovery document.
+// A failed attempt is retried on the next call.
+func (p *Provider) discover(ctx context.Context) error {
+	if p.Type != TypeOIDC || p.Issuer == "" {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := doJSON(req, &doc); err != nil {
+		return fmt.Errorf("oidc discovery failed: %v", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != p.Issuer {
+		return fmt.Errorf("oidc discovery failed: issuer mismatch %q", doc.Issuer)
+	}
+
+	// Explicitly configured endpoints win over discovery
+	setDefault(&p.AuthURL, doc.AuthorizationEndpoint)
+	setDefault(&p.TokenURL, doc.TokenEndpoint)
+	setDefault(&p.UserInfoURL, doc.UserinfoEndpoint)
+	if p.AuthURL == "" || p.TokenURL == "" || p.UserInfoURL == "" {
+		return fmt.Errorf("oidc discovery failed: missing endpoints")
+	}
+
+	p.discovered = true
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
+
+func setDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
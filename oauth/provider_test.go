@@ -0,0 +1,248 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeProvider is a local OAuth2/OIDC provider. It remembers the PKCE challenge of the
+// last authorization request and only issues a token for the matching verifier.
+type fakeProvider struct {
+	*httptest.Server
+	issuer        string // discovery reports this issuer, the server URL unless set
+	challenge     string
+	emailVerified interface{}
+	emails        []map[string]interface{}
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	f := &fakeProvider{emailVerified: true}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := f.issuer
+		if issuer == "" {
+			issuer = f.URL
+		}
+		writeJSON(w, map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": f.URL + "/authorize",
+			"token_endpoint":         f.URL + "/token",
+			"userinfo_endpoint":      f.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != f.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, map[string]string{"access_token": "fake-access-token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"sub":                "subject-1",
+			"email":              "jane@example.com",
+			"email_verified":     f.emailVerified,
+			"preferred_username": "jane",
+			"name":               "Jane Doe",
+		})
+	})
+	mux.HandleFunc("/github/user", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"id": 42, "login": "octocat", "name": "Mona Lisa", "email": "public@example.com"})
+	})
+	mux.HandleFunc("/github/emails", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, f.emails)
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// authorize runs the browser half of the flow: it records the challenge the provider
+// would see on its authorization page
+func (f *fakeProvider) authorize(t *testing.T, p *Provider, state, challenge string) {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid auth URL %q: %v", authURL, err)
+	}
+	if !strings.HasPrefix(authURL, f.URL+"/authorize?") {
+		t.Fatalf("auth URL %q doesn't point at the discovered endpoint", authURL)
+	}
+	query := parsed.Query()
+	if query.Get("state") != state || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth URL parameters %v", query)
+	}
+	f.challenge = query.Get("code_challenge")
+}
+
+func authorized(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer fake-access-token"
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func initProvider(t *testing.T, name string, vars map[string]string) *Provider {
+	t.Helper()
+	t.Setenv("OAUTH_PROVIDERS", name)
+	t.Setenv("OAUTH_REDIRECT_BASE_URL", "http://localhost:3000")
+	for key, value := range vars {
+		t.Setenv("OAUTH_"+strings.ToUpper(name)+"_"+key, value)
+	}
+	if err := Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	p, ok := Get(name)
+	if !ok {
+		t.Fatalf("provider %s not loaded", name)
+	}
+	return p
+}
+
+func TestOIDCDiscoveryAndPKCEExchange(t *testing.T) {
+	fake := newFakeProvider(t)
+	p := initProvider(t, "fake", map[string]string{"TYPE": TypeOIDC, "CLIENT_ID": "client", "ISSUER": fake.URL + "/"})
+
+	verifier, challenge := NewPKCE()
+	fake.authorize(t, p, "state-1", challenge)
+	if p.TokenURL != fake.URL+"/token" || p.UserInfoURL != fake.URL+"/userinfo" {
+		t.Fatalf("endpoints not discovered: token %q, userinfo %q", p.TokenURL, p.UserInfoURL)
+	}
+	if p.RedirectURL != "http://localhost:3000/api/auth/fake/callback" {
+		t.Fatalf("unexpected redirect URL %q", p.RedirectURL)
+	}
+
+	if _, err := p.Exchange(context.Background(), "good-code", "wrong-verifier"); err == nil {
+		t.Fatal("exchange with the wrong PKCE verifier succeeded")
+	}
+	if _, err := p.Exchange(context.Background(), "bad-code", verifier); err == nil {
+		t.Fatal("exchange with an unknown code succeeded")
+	}
+	accessToken, err := p.Exchange(context.Background(), "good-code", verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	identity, err := p.FetchIdentity(context.Background(), accessToken)
+	if err != nil {
+		t.Fatalf("FetchIdentity: %v", err)
+	}
+	want := Identity{Subject: "subject-1", Email: "jane@example.com", EmailVerified: true, Username: "jane", Name: "Jane Doe"}
+	if identity != want {
+		t.Fatalf("identity = %+v, want %+v", identity, want)
+	}
+}
+
+func TestOIDCDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.issuer = "https://evil.example.com"
+	p := initProvider(t, "fake", map[string]string{"TYPE": TypeOIDC, "CLIENT_ID": "client", "ISSUER": fake.URL})
+
+	_, err := p.AuthCodeURL(context.Background(), "state", "challenge")
+	if err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Fatalf("AuthCodeURL error = %v, want issuer mismatch", err)
+	}
+	if _, err := p.Exchange(context.Background(), "good-code", "verifier"); err == nil {
+		t.Fatal("exchange succeeded after failed discovery")
+	}
+}
+
+func TestOIDCEmailVerifiedFormats(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{true, true},
+		{"true", true},
+		{false, false},
+		{"false", false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		fake := newFakeProvider(t)
+		fake.emailVerified = tt.value
+		p := initProvider(t, "fake", map[string]string{"TYPE": TypeOIDC, "CLIENT_ID": "client", "ISSUER": fake.URL})
+
+		identity, err := p.FetchIdentity(context.Background(), "fake-access-token")
+		if err != nil {
+			t.Fatalf("email_verified %#v: FetchIdentity: %v", tt.value, err)
+		}
+		if identity.EmailVerified != tt.want {
+			t.Errorf("email_verified %#v: EmailVerified = %v, want %v", tt.value, identity.EmailVerified, tt.want)
+		}
+	}
+}
+
+func TestGitHubUsesPrimaryVerifiedEmail(t *testing.T) {
+	fake := newFakeProvider(t)
+	p := initProvider(t, "github", map[string]string{
+		"TYPE":         TypeGitHub,
+		"CLIENT_ID":    "client",
+		"AUTH_URL":     fake.URL + "/authorize",
+		"TOKEN_URL":    fake.URL + "/token",
+		"USERINFO_URL": fake.URL + "/github/user",
+		"EMAILS_URL":   fake.URL + "/github/emails",
+	})
+
+	verifier, challenge := NewPKCE()
+	fake.authorize(t, p, "state", challenge)
+	accessToken, err := p.Exchange(context.Background(), "good-code", verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	fake.emails = []map[string]interface{}{
+		{"email": "unverified@example.com", "primary": true, "verified": false},
+		{"email": "secondary@example.com", "primary": false, "verified": true},
+	}
+	identity, err := p.FetchIdentity(context.Background(), accessToken)
+	if err != nil {
+		t.Fatalf("FetchIdentity: %v", err)
+	}
+	if identity.Email != "" || identity.EmailVerified {
+		t.Fatalf("identity without a primary verified email got %q (verified %v)", identity.Email, identity.EmailVerified)
+	}
+	if identity.Subject != "42" || identity.Username != "octocat" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	fake.emails = append(fake.emails, map[string]interface{}{"email": "primary@example.com", "primary": true, "verified": true})
+	identity, err = p.FetchIdentity(context.Background(), accessToken)
+	if err != nil {
+		t.Fatalf("FetchIdentity: %v", err)
+	}
+	if identity.Email != "primary@example.com" || !identity.EmailVerified {
+		t.Fatalf("identity email = %q (verified %v), want the primary verified address", identity.Email, identity.EmailVerified)
+	}
+}
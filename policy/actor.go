@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// Actor is whoever performs an action: a logged in user, an admin, or an anonymous reader
+type Actor struct {
+	UserID    string
+	AdminID   string
+	AdminRole string
+	// Lookups answers the rules' questions about roles and collaborators; nil means the database
+	Lookups Lookups
+}
+
+// ActorFromContext builds the actor from the locals set by AuthMiddleware or AdminAuthMiddleware
+func ActorFromContext(c *fiber.Ctx) Actor {
+	var actor Actor
+	actor.UserID, _ = c.Locals("userID").(string)
+	actor.AdminID, _ = c.Locals("adminID").(string)
+	actor.AdminRole, _ = c.Locals("adminRole").(string)
+	actor.Lookups, _ = c.Locals("policyLookups").(Lookups)
+	return actor
+}
+
+// IsAdmin reports whether the actor is authenticated as an admin
+func (a Actor) IsAdmin() bool {
+	return a.AdminRole != ""
+}
+
+// adminCan reports whether the actor is an admin whose role holds the permission
+func (a Actor) adminCan(permission string) bool {
+	if !a.IsAdmin() {
+		return false
+	}
+	allowed, err := a.lookups().RoleHasPermissions(a.AdminRole, permission)
+	return err == nil && allowed
+}
+
+func (a Actor) lookups() Lookups {
+	if a.Lookups == nil {
+		return DatabaseLookups{}
+	}
+	return a.Lookups
+}
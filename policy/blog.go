@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"github.com/nurullahgd/main-blog-backend/models"
+	"gorm.io/gorm"
+)
+
+// CanViewBlog reports whether the actor may read a post. Published and unlisted posts
+// are public; drafts, scheduled and archived posts only show to people who can edit them.
+func CanViewBlog(actor Actor, blog models.Blog) bool {
+	switch blog.Status {
+	case models.BlogStatusPublished, models.BlogStatusUnlisted:
+		return true
+	}
+	return CanEditBlog(actor, blog)
+}
+
+// ListableBlogs narrows a query on blogs to the posts the actor may find in listings:
+// published posts for everyone, plus the posts the actor can edit. Unlisted posts are
+// only reachable by link, so they aren't listed to other readers.
+func ListableBlogs(actor Actor, query *gorm.DB) *gorm.DB {
+	if actor.adminCan(models.PermBlogsModerate) {
+		return query
+	}
+	if actor.UserID == "" {
+		return query.Where("blogs.status = ?", models.BlogStatusPublished)
+	}
+	return query.Where(
+		"(blogs.status = ? OR blogs.user_id = ? OR blogs.id IN (SELECT blog_id FROM blog_collaborators WHERE user_id = ? AND role IN ?))",
+		models.BlogStatusPublished, actor.UserID, actor.UserID,
+		[]string{models.CollaboratorRoleCoAuthor, models.CollaboratorRoleEditor},
+	)
+}
+
+// CanEditBlog reports whether the actor may change a post's content and images.
+// Owners, co-authors, editors and admins with blogs:moderate can.
+func CanEditBlog(actor Actor, blog models.Blog) bool {
+	if isOwner(actor, blog) || actor.adminCan(models.PermBlogsModerate) {
+		return true
+	}
+
+	switch collaboratorRole(actor, blog) {
+	case models.CollaboratorRoleCoAuthor, models.CollaboratorRoleEditor:
+		return true
+	}
+	return false
+}
+
+// CanPublishBlog reports whether the actor may change who can see a post.
+// Editors can't; owners, co-authors and moderating admins can.
+func CanPublishBlog(actor Actor, blog models.Blog) bool {
+	if isOwner(actor, blog) || actor.adminCan(models.PermBlogsModerate) {
+		return true
+	}
+	return collaboratorRole(actor, blog) == models.CollaboratorRoleCoAuthor
+}
+
+// CanDeleteBlog reports whether the actor may delete a post. Only the owner and moderating admins can.
+func CanDeleteBlog(actor Actor, blog models.Blog) bool {
+	return isOwner(actor, blog) || actor.adminCan(models.PermBlogsModerate)
+}
+
+// CanManageBlogSettings reports whether the actor may change a post's settings, such as revision retention
+func CanManageBlogSettings(actor Actor, blog models.Blog) bool {
+	return isOwner(actor, blog) || actor.adminCan(models.PermBlogsModerate)
+}
+
+// CanManageCollaborators reports whether the actor may add or remove a post's collaborators
+func CanManageCollaborators(actor Actor, blog models.Blog) bool {
+	return isOwner(actor, blog) || actor.adminCan(models.PermBlogsModerate)
+}
+
+func isOwner(actor Actor, blog models.Blog) bool {
+	return actor.UserID != "" && actor.UserID == blog.UserID
+}
+
+// collaboratorRole returns the actor's collaborator role on the post, or "" if they have none
+func collaboratorRole(actor Actor, blog models.Blog) string {
+	if actor.UserID == "" {
+		return ""
+	}
+
+	role, err := actor.lookups().CollaboratorRole(blog.ID, actor.UserID)
+	if err != nil {
+		return ""
+	}
+	return role
+}
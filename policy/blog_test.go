@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	ownerID    = "00000000-0000-0000-0000-000000000001"
+	coauthorID = "00000000-0000-0000-0000-000000000002"
+	editorID   = "00000000-0000-0000-0000-000000000003"
+	strangerID = "00000000-0000-0000-0000-000000000004"
+
+	moderatorRole = "moderator"
+	supportRole   = "support"
+)
+
+// stubLookups answers from memory: moderatorRole holds blogs:moderate, supportRole
+// holds nothing, and the post has a co-author and an editor
+type stubLookups struct {
+	blogID uuid.UUID
+}
+
+func (stubLookups) RoleHasPermissions(role string, permissions ...string) (bool, error) {
+	if role != moderatorRole {
+		return false, nil
+	}
+	for _, permission := range permissions {
+		if permission != models.PermBlogsModerate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s stubLookups) CollaboratorRole(blogID uuid.UUID, userID string) (string, error) {
+	collaborators := map[string]string{
+		coauthorID: models.CollaboratorRoleCoAuthor,
+		editorID:   models.CollaboratorRoleEditor,
+	}
+	if role, ok := collaborators[userID]; ok && blogID == s.blogID {
+		return role, nil
+	}
+	return "", errors.New("record not found")
+}
+
+// actors returns every kind of actor, answering lookups about the post
+func actors(blog models.Blog) map[string]Actor {
+	lookups := stubLookups{blogID: blog.ID}
+	return map[string]Actor{
+		"owner":            {UserID: ownerID, Lookups: lookups},
+		"co-author":        {UserID: coauthorID, Lookups: lookups},
+		"editor":           {UserID: editorID, Lookups: lookups},
+		"moderating admin": {AdminID: "admin-1", AdminRole: moderatorRole, Lookups: lookups},
+		"plain admin":      {AdminID: "admin-2", AdminRole: supportRole, Lookups: lookups},
+		"stranger":         {UserID: strangerID, Lookups: lookups},
+		"anonymous":        {Lookups: lookups},
+	}
+}
+
+func TestBlogRules(t *testing.T) {
+	draft := models.Blog{ID: uuid.New(), UserID: ownerID, Status: models.BlogStatusDraft}
+	actors := actors(draft)
+
+	type rules struct{ view, edit, publish, delete, settings, collaborators bool }
+	tests := map[string]rules{
+		"owner":            {true, true, true, true, true, true},
+		"co-author":        {true, true, true, false, false, false},
+		"editor":           {true, true, false, false, false, false},
+		"moderating admin": {true, true, true, true, true, true},
+		"plain admin":      {},
+		"stranger":         {},
+		"anonymous":        {},
+	}
+
+	for name, want := range tests {
+		actor := actors[name]
+		got := rules{
+			view:          CanViewBlog(actor, draft),
+			edit:          CanEditBlog(actor, draft),
+			publish:       CanPublishBlog(actor, draft),
+			delete:        CanDeleteBlog(actor, draft),
+			settings:      CanManageBlogSettings(actor, draft),
+			collaborators: CanManageCollaborators(actor, draft),
+		}
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestCollaboratorRolesStayOnTheirPost(t *testing.T) {
+	shared := models.Blog{ID: uuid.New(), UserID: ownerID, Status: models.BlogStatusDraft}
+	other := models.Blog{ID: uuid.New(), UserID: ownerID, Status: models.BlogStatusDraft}
+
+	actors := actors(shared)
+	for _, name := range []string{"co-author", "editor"} {
+		if CanViewBlog(actors[name], other) || CanEditBlog(actors[name], other) || CanPublishBlog(actors[name], other) {
+			t.Errorf("%s has access to another post of the owner", name)
+		}
+	}
+}
+
+func TestPublicPostsAreVisibleToEveryone(t *testing.T) {
+	for _, status := range []string{models.BlogStatusPublished, models.BlogStatusUnlisted} {
+		blog := models.Blog{ID: uuid.New(), UserID: ownerID, Status: status}
+		for name, actor := range actors(blog) {
+			if !CanViewBlog(actor, blog) {
+				t.Errorf("%s post hidden from %s", status, name)
+			}
+		}
+	}
+}
+
+func TestListableBlogs(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors := actors(models.Blog{})
+	listed := func(actor Actor) *gorm.Statement {
+		var blogs []models.Blog
+		return ListableBlogs(actor, db.Model(&models.Blog{})).Find(&blogs).Statement
+	}
+
+	if stmt := listed(actors["moderating admin"]); strings.Contains(stmt.SQL.String(), "status") {
+		t.Errorf("moderating admin: listing is narrowed: %s", stmt.SQL.String())
+	}
+
+	for _, name := range []string{"plain admin", "anonymous"} {
+		stmt := listed(actors[name])
+		if !strings.Contains(stmt.SQL.String(), "blogs.status = $1") || len(stmt.Vars) != 1 || stmt.Vars[0] != models.BlogStatusPublished {
+			t.Errorf("%s: want only published posts, got %s %v", name, stmt.SQL.String(), stmt.Vars)
+		}
+	}
+
+	for _, name := range []string{"owner", "co-author", "editor", "stranger"} {
+		actor := actors[name]
+		stmt := listed(actor)
+		sql := stmt.SQL.String()
+		if !strings.Contains(sql, "blogs.status = $1 OR blogs.user_id = $2 OR blogs.id IN (SELECT blog_id FROM blog_collaborators WHERE user_id = $3 AND role IN ($4,$5))") {
+			t.Errorf("%s: unexpected listing query %s", name, sql)
+			continue
+		}
+		want := []interface{}{models.BlogStatusPublished, actor.UserID, actor.UserID, models.CollaboratorRoleCoAuthor, models.CollaboratorRoleEditor}
+		if !reflect.DeepEqual(stmt.Vars, want) {
+			t.Errorf("%s: vars = %v, want %v", name, stmt.Vars, want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/helpers"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+// Lookups answers the questions the rules ask about stored data
+type Lookups interface {
+	// RoleHasPermissions reports whether the admin role holds every permission
+	RoleHasPermissions(role string, permissions ...string) (bool, error)
+	// CollaboratorRole returns the user's collaborator role on the post
+	CollaboratorRole(blogID uuid.UUID, userID string) (string, error)
+}
+
+// DatabaseLookups answers lookups from the database. Actors without Lookups use it.
+type DatabaseLookups struct{}
+
+func (DatabaseLookups) RoleHasPermissions(role string, permissions ...string) (bool, error) {
+	return helpers.RoleHasPermissions(role, permissions...)
+}
+
+func (DatabaseLookups) CollaboratorRole(blogID uuid.UUID, userID string) (string, error) {
+	var collaborator models.BlogCollaborator
+	err := database.DB.
+		Where("blog_id = ? AND user_id = ?", blogID, userID).
+		First(&collaborator).Error
+	return collaborator.Role, err
+}
+
+// UseLookups makes ActorFromContext hand out actors that answer from lookups
+func UseLookups(lookups Lookups) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("policyLookups", lookups)
+		return c.Next()
+	}
+}
@@ -4,45 +4,136 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/nurullahgd/main-blog-backend/controllers"
 	"github.com/nurullahgd/main-blog-backend/middleware"
+	"github.com/nurullahgd/main-blog-backend/models"
 )
 
 func SetupRoutes(app *fiber.App) {
+	// Cookie-authenticated writes must echo the csrf_token cookie in X-CSRF-Token
+	app.Use(middleware.CSRFProtection())
+
+	// Public signing keys for services verifying our tokens
+	app.Get("/.well-known/jwks.json", controllers.GetJWKS)
+	app.Get("/api/csrf", controllers.GetCSRFToken)
+
 	// User routes (user panel)
 	userRoutes := app.Group("/api/users")
 	userRoutes.Get("/", controllers.GetUsers)
+	// Static GET routes must be registered before /:id
+	userRoutes.Get("/sessions", middleware.AuthMiddleware(), middleware.RequireSession(), controllers.GetSessions)
+	userRoutes.Get("/identities", middleware.AuthMiddleware(), middleware.RequireSession(), controllers.GetIdentities)
+	userRoutes.Get("/tokens", middleware.AuthMiddleware(), middleware.RequireSession(), controllers.GetAccessTokens)
 	userRoutes.Get("/:id", controllers.GetUser)
 	userRoutes.Post("/register", controllers.Register)
 	userRoutes.Post("/login", controllers.Login)
+	userRoutes.Post("/login/2fa", controllers.LoginTwoFactor)
 	userRoutes.Post("/logout", controllers.Logout)
+	userRoutes.Post("/refresh", controllers.RefreshSession)
+	userRoutes.Post("/password/forgot", controllers.ForgotPassword)
+	userRoutes.Post("/password/reset", controllers.ResetPassword)
+	userRoutes.Get("/verify-email", controllers.VerifyEmail)
 
 	// Protected user routes (user panel)
 	protectedUserRoutes := userRoutes.Group("/", middleware.AuthMiddleware())
-	protectedUserRoutes.Put("/edit", controllers.EditUser)
-	protectedUserRoutes.Post("/profile-image", controllers.UploadProfileImage)
-
-// Blog routes (blog panel)
-blogRoutes := app.Group("/api/blogs")
-blogRoutes.Get("/", controllers.GetBlogs)
-
-// Daha spesifik route'lar önce gelmeli
-// Protected blog routes (blog panel)
-protectedBlogRoutes := blogRoutes.Group("/", middleware.AuthMiddleware())
-protectedBlogRoutes.Get("/fetchBlogs", controllers.FetchMyBlogs)
-protectedBlogRoutes.Post("/createBlog", controllers.CreateBlog)
-protectedBlogRoutes.Post("/visibility/:id", controllers.ChangeVisibility)
-protectedBlogRoutes.Post("/editBlog/:id", controllers.EditBlog)
-protectedBlogRoutes.Delete("/:id", controllers.DeleteBlog)
-protectedBlogRoutes.Post("/:id/main-image", controllers.UploadBlogImage)
-
-// En sona bırak: ID ile yapılan get işlemi
-blogRoutes.Get("/:id", controllers.GetBlog)
-
-	// Admin routes (admin panel)
-	adminRoutes := app.Group("/api/admin", middleware.AdminAuthMiddleware())
-	adminRoutes.Get("/getUsers", controllers.GetUsers)
-	adminRoutes.Delete("/blogDelete/:id", controllers.DeleteBlogFromAdmin)
-	adminRoutes.Delete("/userDelete/:id", controllers.DeleteUserFromAdmin)
-
-	adminRoutes.Get("/users", controllers.GetAdminUsers)
-	adminRoutes.Post("/users", controllers.CreateAdminUser)
+	protectedUserRoutes.Put("/edit", middleware.RequireScope(models.ScopeProfileWrite), controllers.EditUser)
+	protectedUserRoutes.Post("/profile-image", middleware.RequireScope(models.ScopeMediaWrite), controllers.UploadProfileImage)
+
+	// Account management routes below are not reachable with personal access tokens
+	protectedUserRoutes.Use(middleware.RequireSession())
+	protectedUserRoutes.Put("/password", controllers.ChangePassword)
+	protectedUserRoutes.Post("/verify-email/resend", controllers.ResendVerificationEmail)
+	protectedUserRoutes.Post("/2fa/enroll", controllers.EnrollTwoFactor)
+	protectedUserRoutes.Post("/2fa/confirm", controllers.ConfirmTwoFactor)
+	protectedUserRoutes.Post("/2fa/disable", controllers.DisableTwoFactor)
+	protectedUserRoutes.Delete("/sessions", controllers.RevokeAllSessions)
+	protectedUserRoutes.Delete("/sessions/:id", controllers.RevokeSession)
+	protectedUserRoutes.Get("/identities/:provider/link", controllers.LinkIdentity)
+	protectedUserRoutes.Delete("/identities/:id", controllers.UnlinkIdentity)
+	protectedUserRoutes.Post("/tokens", controllers.CreateAccessToken)
+	protectedUserRoutes.Delete("/tokens/:id", controllers.RevokeAccessToken)
+
+	// Social login routes
+	authRoutes := app.Group("/api/auth")
+	authRoutes.Get("/providers", controllers.GetOAuthProviders)
+	authRoutes.Get("/:provider/login", controllers.OAuthLogin)
+	authRoutes.Get("/:provider/callback", controllers.OAuthCallback)
+
+	// Blog routes (blog panel)
+	blogRoutes := app.Group("/api/blogs")
+	blogRoutes.Get("/", controllers.GetBlogs)
+	// Static GET routes must be registered before /:id, which has to come before the
+	// protected group so anonymous readers aren't stopped by its AuthMiddleware
+	blogRoutes.Get("/search", middleware.OptionalAuthMiddleware(), controllers.SearchBlogs)
+	blogRoutes.Get("/fetchBlogs", middleware.AuthMiddleware(), middleware.RequireScope(models.ScopeBlogsRead), controllers.FetchMyBlogs)
+	// Authors, collaborators and moderators also see unpublished posts
+	blogRoutes.Get("/:id", middleware.OptionalAuthMiddleware(), controllers.GetBlog)
+
+	// Daha spesifik route'lar önce gelmeli
+	// Protected blog routes (blog panel)
+	protectedBlogRoutes := blogRoutes.Group("/", middleware.AuthMiddleware())
+	protectedBlogRoutes.Post("/createBlog", middleware.RequireScope(models.ScopeBlogsWrite), middleware.RequireVerifiedEmail(), controllers.CreateBlog)
+	protectedBlogRoutes.Post("/visibility/:id", middleware.RequireScope(models.ScopeBlogsWrite), controllers.ChangeVisibility)
+	protectedBlogRoutes.Put("/:id/status", middleware.RequireScope(models.ScopeBlogsWrite), controllers.UpdateBlogStatus)
+	protectedBlogRoutes.Post("/editBlog/:id", middleware.RequireScope(models.ScopeBlogsWrite), controllers.EditBlog)
+	protectedBlogRoutes.Patch("/:id", middleware.RequireScope(models.ScopeBlogsWrite), controllers.PatchBlog)
+	protectedBlogRoutes.Delete("/:id", middleware.RequireScope(models.ScopeBlogsWrite), controllers.DeleteBlog)
+	protectedBlogRoutes.Post("/:id/main-image", middleware.RequireScope(models.ScopeMediaWrite), controllers.UploadBlogImage)
+	protectedBlogRoutes.Get("/:id/revisions", middleware.RequireScope(models.ScopeBlogsRead), controllers.GetBlogRevisions)
+	protectedBlogRoutes.Get("/:id/revisions/diff", middleware.RequireScope(models.ScopeBlogsRead), controllers.DiffBlogRevisions)
+	protectedBlogRoutes.Get("/:id/revisions/:number", middleware.RequireScope(models.ScopeBlogsRead), controllers.GetBlogRevision)
+	protectedBlogRoutes.Post("/:id/revisions/:number/restore", middleware.RequireScope(models.ScopeBlogsWrite), controllers.RestoreBlogRevision)
+	protectedBlogRoutes.Put("/:id/revisions/retention", middleware.RequireScope(models.ScopeBlogsWrite), controllers.UpdateRevisionRetention)
+	protectedBlogRoutes.Get("/:id/collaborators", middleware.RequireScope(models.ScopeBlogsRead), controllers.GetBlogCollaborators)
+	protectedBlogRoutes.Post("/:id/collaborators", middleware.RequireScope(models.ScopeBlogsWrite), controllers.AddBlogCollaborator)
+	protectedBlogRoutes.Delete("/:id/collaborators/:userId", middleware.RequireScope(models.ScopeBlogsWrite), controllers.RemoveBlogCollaborator)
+
+	// Tag routes
+	tagRoutes := app.Group("/api/tags")
+	tagRoutes.Get("/", controllers.GetTags)
+	tagRoutes.Get("/autocomplete", controllers.AutocompleteTags)
+	tagRoutes.Get("/:slug/blogs", controllers.GetTagBlogs)
+
+	// Category routes
+	categoryRoutes := app.Group("/api/categories")
+	categoryRoutes.Get("/", controllers.GetCategories)
+	categoryRoutes.Get("/:slug/blogs", controllers.GetCategoryBlogs)
+
+	// Admin auth routes (admin panel)
+	adminAuthRoutes := app.Group("/api/admin")
+	adminAuthRoutes.Post("/login", controllers.AdminLogin)
+	adminAuthRoutes.Post("/login/2fa", controllers.AdminLoginTwoFactor)
+	adminAuthRoutes.Post("/logout", controllers.AdminLogout)
+	adminAuthRoutes.Post("/bootstrap", controllers.BootstrapAdmin)
+
+	// Protected admin routes (admin panel)
+	adminRoutes := adminAuthRoutes.Group("/", middleware.AdminAuthMiddleware())
+	// 2FA setup must stay reachable while the admin is blocked by RequireAdminTwoFactor
+	adminRoutes.Post("/2fa/enroll", controllers.EnrollAdminTwoFactor)
+	adminRoutes.Post("/2fa/confirm", controllers.ConfirmAdminTwoFactor)
+	adminRoutes.Use(middleware.RequireAdminTwoFactor())
+	adminRoutes.Post("/2fa/disable", controllers.DisableAdminTwoFactor)
+	adminRoutes.Get("/getUsers", middleware.RequirePermission(models.PermUsersRead), controllers.GetUsers)
+	adminRoutes.Delete("/blogDelete/:id", middleware.RequirePermission(models.PermBlogsModerate), controllers.DeleteBlogFromAdmin)
+	// Moderators edit posts through the same handlers as authors; the blog policy decides
+	adminRoutes.Post("/blogs/:id/edit", middleware.RequirePermission(models.PermBlogsModerate), controllers.EditBlog)
+	adminRoutes.Patch("/blogs/:id", middleware.RequirePermission(models.PermBlogsModerate), controllers.PatchBlog)
+	adminRoutes.Post("/blogs/:id/visibility", middleware.RequirePermission(models.PermBlogsModerate), controllers.ChangeVisibility)
+	adminRoutes.Put("/blogs/:id/status", middleware.RequirePermission(models.PermBlogsModerate), controllers.UpdateBlogStatus)
+	adminRoutes.Post("/blogs/:id/main-image", middleware.RequirePermission(models.PermBlogsModerate), controllers.UploadBlogImage)
+	adminRoutes.Put("/tags/:id", middleware.RequirePermission(models.PermBlogsModerate), controllers.RenameTag)
+	adminRoutes.Post("/tags/:id/merge", middleware.RequirePermission(models.PermBlogsModerate), controllers.MergeTag)
+	adminRoutes.Post("/categories", middleware.RequirePermission(models.PermCategoriesManage), controllers.CreateCategory)
+	adminRoutes.Put("/categories/:id", middleware.RequirePermission(models.PermCategoriesManage), controllers.UpdateCategory)
+	adminRoutes.Delete("/categories/:id", middleware.RequirePermission(models.PermCategoriesManage), controllers.DeleteCategory)
+	adminRoutes.Delete("/userDelete/:id", middleware.RequirePermission(models.PermUsersDelete), controllers.DeleteUserFromAdmin)
+	adminRoutes.Delete("/users/:id/sessions", middleware.RequirePermission(models.PermUsersSessions), controllers.RevokeUserSessionsFromAdmin)
+	adminRoutes.Post("/users/:id/unlock", middleware.RequirePermission(models.PermUsersUnlock), controllers.UnlockUserFromAdmin)
+
+	adminRoutes.Get("/users", middleware.RequirePermission(models.PermAdminsRead), controllers.GetAdminUsers)
+	adminRoutes.Post("/users", middleware.RequirePermission(models.PermAdminsCreate), controllers.CreateAdminUser)
+
+	adminRoutes.Get("/roles", middleware.RequirePermission(models.PermRolesManage), controllers.GetRoles)
+	adminRoutes.Put("/roles/:role", middleware.RequirePermission(models.PermRolesManage), controllers.UpdateRolePermissions)
+
+	adminRoutes.Get("/settings/2fa", middleware.RequirePermission(models.PermSettingsManage), controllers.GetAdminTwoFactorSetting)
+	adminRoutes.Put("/settings/2fa", middleware.RequirePermission(models.PermSettingsManage), controllers.UpdateAdminTwoFactorSetting)
 }
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	// publishBatchSize bounds how many rows one replica locks per statement
+	publishBatchSize = 100
+)
+
+// Start runs the publisher in the background. The interval comes from
+// SCHEDULER_INTERVAL (a Go duration such as "30s"); "0" disables the scheduler.
+func Start() {
+	interval := defaultInterval
+	if value := os.Getenv("SCHEDULER_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Println("Invalid SCHEDULER_INTERVAL, using default:", err)
+		} else {
+			interval = parsed
+		}
+	}
+	if interval <= 0 {
+		log.Println("Post scheduler disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if published, err := PublishDuePosts(); err != nil {
+				log.Println("Failed to publish scheduled posts:", err)
+			} else if published > 0 {
+				log.Printf("Published %d scheduled posts", published)
+			}
+		}
+	}()
+}
+
+// PublishDuePosts publishes every scheduled post whose time has come. A post that was
+// published before keeps its original publication date. Rows are claimed
+// with FOR UPDATE SKIP LOCKED, so several replicas can run it at once without
+// publishing the same post twice or blocking each other.
+func PublishDuePosts() (int64, error) {
+	var total int64
+	for {
+		result := database.DB.Exec(`
+			UPDATE blogs
+			SET status = ?, visibility = true, published_at = COALESCE(published_at, scheduled_at), scheduled_at = NULL,
+				version = version + 1, updated_at = NOW()
+			WHERE id IN (
+				SELECT id FROM blogs
+				WHERE status = ? AND scheduled_at <= NOW() AND deleted_at IS NULL
+				ORDER BY scheduled_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)`,
+			models.BlogStatusPublished, models.BlogStatusScheduled, publishBatchSize,
+		)
+		if result.Error != nil {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+		if result.RowsAffected < publishBatchSize {
+			return total, nil
+		}
+	}
+}
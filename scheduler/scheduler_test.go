@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nurullahgd/main-blog-backend/database"
+	"github.com/nurullahgd/main-blog-backend/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestPublishDuePosts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = gormDB
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+	})
+
+	// A full batch means more may be due, so the publisher goes on until a batch comes back short.
+	// Posts published before and rescheduled keep their original publication date.
+	publish := regexp.QuoteMeta("published_at = COALESCE(published_at, scheduled_at), scheduled_at = NULL")
+	mock.ExpectExec(publish).
+		WithArgs(models.BlogStatusPublished, models.BlogStatusScheduled, publishBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, publishBatchSize))
+	mock.ExpectExec(publish).
+		WithArgs(models.BlogStatusPublished, models.BlogStatusScheduled, publishBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	published, err := PublishDuePosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != publishBatchSize+3 {
+		t.Errorf("published %d posts, want %d", published, publishBatchSize+3)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
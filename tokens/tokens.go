@@ -0,0 +1,232 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Issuer is the iss claim of every token this service signs
+const Issuer = "main-blog-backend"
+
+// The aud claim tells token types apart. Callers parse with jwt.WithAudience for the type
+// they expect, so a token signed for one purpose is rejected everywhere else, including
+// by services verifying user tokens against the published JWKS.
+const (
+	AudienceAccess            = "main-blog-backend/access"
+	AudienceAdmin             = "main-blog-backend/admin"
+	AudienceTwoFactor         = "main-blog-backend/2fa"
+	AudienceEmailVerification = "main-blog-backend/email-verification"
+	AudienceOAuthState        = "main-blog-backend/oauth-state"
+)
+
+// legacyKeyID identifies the shared JWT_SECRET. Tokens without a kid header
+// were signed with it before key rotation existed.
+const legacyKeyID = "hs256"
+
+// key is a single signing or verification key
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for verify-only (retired) keys
+	verifyKey interface{}
+}
+
+var (
+	keys      = map[string]*key{}
+	activeKey *key
+)
+
+// Init loads the key set from the environment:
+//
+//	JWT_KEYS       comma separated kid=path entries pointing at PEM files. Private keys
+//	               (RSA or Ed25519) can sign and verify, public keys only verify, which
+//	               is how a retired key is kept around until its tokens expire.
+//	JWT_ACTIVE_KEY kid used to sign new tokens, defaults to the first private key.
+//	JWT_SECRET     legacy HS256 secret. Used to sign when no JWT_KEYS are configured,
+//	               otherwise only accepted for verification.
+func Init() error {
+	keys = map[string]*key{}
+	activeKey = nil
+
+	var firstPrivate *key
+	for _, entry := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return fmt.Errorf("invalid JWT_KEYS entry %q, expected kid=path", entry)
+		}
+		if kid == legacyKeyID {
+			return fmt.Errorf("kid %q is reserved", legacyKeyID)
+		}
+
+		k, err := loadKey(kid, path)
+		if err != nil {
+			return err
+		}
+		keys[kid] = k
+		if firstPrivate == nil && k.signKey != nil {
+			firstPrivate = k
+		}
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		keys[legacyKeyID] = &key{
+			id:        legacyKeyID,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}
+	}
+
+	switch active := os.Getenv("JWT_ACTIVE_KEY"); {
+	case active != "":
+		k, ok := keys[active]
+		if !ok || k.signKey == nil {
+			return fmt.Errorf("JWT_ACTIVE_KEY %q is not a loaded private key", active)
+		}
+		activeKey = k
+	case firstPrivate != nil:
+		activeKey = firstPrivate
+	default:
+		activeKey = keys[legacyKeyID]
+	}
+
+	if activeKey == nil {
+		return errors.New("no signing key configured, set JWT_KEYS or JWT_SECRET")
+	}
+	return nil
+}
+
+// Sign signs claims with the active key and sets the kid header
+func Sign(claims jwt.Claims) (string, error) {
+	if activeKey == nil {
+		return "", errors.New("token service not initialized")
+	}
+
+	token := jwt.NewWithClaims(activeKey.method, claims)
+	token.Header["kid"] = activeKey.id
+	return token.SignedString(activeKey.signKey)
+}
+
+// Parse verifies a token against the key named by its kid header and fills claims.
+// Options such as jwt.WithAudience and jwt.WithIssuer add claim checks.
+func Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = legacyKeyID
+		}
+
+		k, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		// Each key only verifies its own algorithm, so an RSA public key can never be used as an HMAC secret
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return k.verifyKey, nil
+	}, options...)
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns every asymmetric verification key. The HS256 secret is never published.
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range keys {
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// loadKey reads an RSA or Ed25519 key from a PEM file
+func loadKey(kid, path string) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %v", kid, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM block found", kid)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM type %q", kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %v", kid, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &key{id: kid, method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &key{id: kid, method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	case ed25519.PrivateKey:
+		return &key{id: kid, method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &key{id: kid, method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", kid, parsed)
+	}
+}
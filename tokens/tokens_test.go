@@ -0,0 +1,46 @@
+package tokens
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseChecksAudienceAndIssuer(t *testing.T) {
+	t.Setenv("JWT_KEYS", "")
+	t.Setenv("JWT_ACTIVE_KEY", "")
+	t.Setenv("JWT_SECRET", "test-secret")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+		token, err := Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	access := []jwt.ParserOption{jwt.WithAudience(AudienceAccess), jwt.WithIssuer(Issuer)}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		valid  bool
+	}{
+		{"access token", jwt.MapClaims{"iss": Issuer, "aud": AudienceAccess}, true},
+		{"2FA challenge", jwt.MapClaims{"iss": Issuer, "aud": AudienceTwoFactor}, false},
+		{"admin token", jwt.MapClaims{"iss": Issuer, "aud": AudienceAdmin}, false},
+		{"no audience", jwt.MapClaims{"iss": Issuer}, false},
+		{"other issuer", jwt.MapClaims{"iss": "someone-else", "aud": AudienceAccess}, false},
+		{"no issuer", jwt.MapClaims{"aud": AudienceAccess}, false},
+	}
+	for _, tt := range tests {
+		_, err := Parse(sign(tt.claims), jwt.MapClaims{}, access...)
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("%s: accepted as an access token %v, want %v (%v)", tt.name, valid, tt.valid, err)
+		}
+	}
+}
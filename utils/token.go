@@ -5,9 +5,9 @@ import (
 	"encoding/base64"
 )
 
-// GenerateToken creates a random token for user_token management
+// GenerateToken creates a random URL-safe token for refresh tokens and other opaque secrets
 func GenerateToken() string {
 	b := make([]byte, 32)
 	rand.Read(b)
-	return base64.StdEncoding.EncodeToString(b)
+	return base64.RawURLEncoding.EncodeToString(b)
 }